@@ -0,0 +1,138 @@
+package route
+
+import (
+	"iter"
+	"sort"
+)
+
+/*
+路由条目少的槽用rtArray 存放: rtKeys 按网络地址排序，rtVals 是对应的值，查找时线性扫描，
+比哈希表少一次哈希计算，内存也更紧凑。条目数超过arrayMax 时转成rtHash,
+删除到arrayMax/2 时再转回rtArray, 中间留一段距离，避免在临界点反复转换。
+rtHash 不为nil 就用rtHash, 否则用rtArray.
+BenchmarkArrayVsHash 里rtArray/rtHash 每次查找: 8条 4.2ns/9.6ns, 12条 4.5ns/7.6ns, 16条 8.8ns/8.6ns,
+16条左右就持平了，arrayMax 取8 留了余量。
+*/
+const arrayMax = 8
+
+// get return the route of net, must be called with rte locked
+func (rte *rtEntry[T]) get(net NetWork) (T, bool) {
+	if rte.rtHash != nil {
+		v, ok := rte.rtHash[net]
+		return v, ok
+	}
+	for i, k := range rte.rtKeys {
+		if k >= net {
+			if k == net {
+				return rte.rtVals[i], true
+			}
+			break
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// set store the route of net and report whether it existed, must be called with rte locked
+func (rte *rtEntry[T]) set(net NetWork, v T) bool {
+	if rte.rtHash != nil {
+		_, existed := rte.rtHash[net]
+		rte.rtHash[net] = v
+		return existed
+	}
+	i := sort.Search(len(rte.rtKeys), func(i int) bool { return rte.rtKeys[i] >= net })
+	if i < len(rte.rtKeys) && rte.rtKeys[i] == net {
+		rte.rtVals[i] = v
+		return true
+	}
+	if len(rte.rtKeys) == arrayMax {
+		m := make(map[NetWork]T, 2*arrayMax)
+		for j, k := range rte.rtKeys {
+			m[k] = rte.rtVals[j]
+		}
+		m[net] = v
+		rte.rtHash, rte.rtKeys, rte.rtVals = m, nil, nil
+		return false
+	}
+	var zero T
+	rte.rtKeys = append(rte.rtKeys, 0)
+	rte.rtVals = append(rte.rtVals, zero)
+	copy(rte.rtKeys[i+1:], rte.rtKeys[i:])
+	copy(rte.rtVals[i+1:], rte.rtVals[i:])
+	rte.rtKeys[i], rte.rtVals[i] = net, v
+	return false
+}
+
+// del delete the route of net and return the deleted value, must be called with rte locked
+func (rte *rtEntry[T]) del(net NetWork) (T, bool) {
+	var zero T
+	if rte.rtHash != nil {
+		v, ok := rte.rtHash[net]
+		if !ok {
+			return zero, false
+		}
+		delete(rte.rtHash, net)
+		if len(rte.rtHash) <= arrayMax/2 {
+			rte.fill(rte.rtHash)
+		}
+		return v, true
+	}
+	i := sort.Search(len(rte.rtKeys), func(i int) bool { return rte.rtKeys[i] >= net })
+	if i == len(rte.rtKeys) || rte.rtKeys[i] != net {
+		return zero, false
+	}
+	v := rte.rtVals[i]
+	n := len(rte.rtKeys) - 1
+	copy(rte.rtKeys[i:], rte.rtKeys[i+1:])
+	copy(rte.rtVals[i:], rte.rtVals[i+1:])
+	rte.rtVals[n] = zero //don't keep the deleted value alive
+	rte.rtKeys, rte.rtVals = rte.rtKeys[:n], rte.rtVals[:n]
+	return v, true
+}
+
+// count return the number of routes in the slot, must be called with rte locked
+func (rte *rtEntry[T]) count() int {
+	if rte.rtHash != nil {
+		return len(rte.rtHash)
+	}
+	return len(rte.rtKeys)
+}
+
+// all iterate the routes of the slot, the rtArray is in network order but the rtHash is not.
+// must be called with rte locked, and the slot must not be modified during the iteration
+func (rte *rtEntry[T]) all() iter.Seq2[NetWork, T] {
+	return func(yield func(NetWork, T) bool) {
+		if rte.rtHash != nil {
+			for net, v := range rte.rtHash {
+				if !yield(net, v) {
+					return
+				}
+			}
+			return
+		}
+		for i, net := range rte.rtKeys {
+			if !yield(net, rte.rtVals[i]) {
+				return
+			}
+		}
+	}
+}
+
+// fill replace the routes of the slot by m, m is used as the rtHash if it's too large for rtArray.
+// must be called with rte locked
+func (rte *rtEntry[T]) fill(m map[NetWork]T) {
+	if len(m) > arrayMax {
+		rte.rtHash, rte.rtKeys, rte.rtVals = m, nil, nil
+		return
+	}
+	keys := make([]NetWork, 0, arrayMax)
+	for net := range m {
+		keys = append(keys, net)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	vals := make([]T, len(keys), arrayMax)
+	for i, net := range keys {
+		vals[i] = m[net]
+	}
+	rte.rtHash, rte.rtKeys, rte.rtVals = nil, keys, vals
+}
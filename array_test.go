@@ -0,0 +1,32 @@
+package route
+
+import (
+	"fmt"
+	"testing"
+)
+
+// BenchmarkArrayVsHash compare the lookup in rtArray and rtHash of the same n routes, hit and miss in turn.
+// rtArray is filled directly to go past arrayMax
+func BenchmarkArrayVsHash(b *testing.B) {
+	for _, n := range []int{1, 2, 4, 8, 12, 16, 32} {
+		var array, hash rtEntry[int]
+		hash.rtHash = make(map[NetWork]int)
+		keys := make([]NetWork, 2*n)
+		for i := 0; i < n; i++ {
+			net := NetWork(i+1) << 8
+			array.rtKeys, array.rtVals = append(array.rtKeys, net), append(array.rtVals, i)
+			hash.rtHash[net] = i
+			keys[2*i], keys[2*i+1] = net, net+1
+		}
+		b.Run(fmt.Sprintf("array/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				array.get(keys[i%len(keys)])
+			}
+		})
+		b.Run(fmt.Sprintf("hash/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				hash.get(keys[i%len(keys)])
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package route
+
+// RouteLookupBatch resolve ips into out, out[i] is the zero value if there is no route for ips[i].
+// each slot is read locked only once for the whole batch. out must be the same length as ips
+func (rt *RouteTable[T]) RouteLookupBatch(ips []NetWork, out []T) {
+	if len(out) != len(ips) {
+		panic("route: RouteLookupBatch len(out) != len(ips)")
+	}
+
+	var zero T
+	for i := range out {
+		out[i] = zero
+	}
+	found := make([]bool, len(ips))
+	left := len(ips)
+	rtMask := rt.slotMask.Load()
+	for i := 0; rtMask != 0 && left > 0; i++ {
+		if rtMask&1 != 0 {
+			rte := &rt.rts[i]
+			rte.RLock()
+			for k, ip := range ips {
+				if found[k] {
+					continue
+				}
+				if v, ok := rte.get(netKey(i, ip)); ok {
+					out[k], found[k] = v, true //an exception is the zero value, the same as no route
+					left--
+				}
+			}
+			rte.RUnlock()
+		}
+		rtMask >>= 1
+	}
+
+	if left == 0 {
+		return
+	}
+	if def, ok := rt.getDefault(); ok {
+		for k := range out {
+			if !found[k] {
+				out[k] = def
+			}
+		}
+	}
+}
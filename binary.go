@@ -0,0 +1,125 @@
+package route
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+/*
+二进制格式，比json 紧凑，用于进程重启时快速保存和恢复路由表，整数都是大端:
+count uint32 | entry ... | entry
+每个entry: ip [4]byte | maskLen byte | len uint32 | value [len]byte, value 由调用者编码
+AddException 添加的例外, maskLen 的最高位置1(binaryException), len 为0, 没有value
+*/
+
+// binaryException is the flag in the maskLen byte of an exception entry
+const binaryException = 0x80
+
+// MaxBinaryValueLen is the max length of an encoded value, the len read from the input is checked
+// before allocating the buffer, so a corrupted or malicious input can't make ReadBinary allocate gigabytes
+const MaxBinaryValueLen = 1 << 20
+
+// WriteBinary write the routes in the binary format, the values are encoded by encodeValue
+func (rt *RouteTable[T]) WriteBinary(w io.Writer, encodeValue func(v T) ([]byte, error)) error {
+	items := rt.items()
+	bw := bufio.NewWriter(w)
+	var hdr [4 + 1 + 4]byte
+	binary.BigEndian.PutUint32(hdr[:4], uint32(len(items)))
+	if _, err := bw.Write(hdr[:4]); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if item.hole {
+			binary.BigEndian.PutUint32(hdr[:4], uint32(item.net))
+			hdr[4] = byte(maskMaxLen-item.slot) | binaryException
+			binary.BigEndian.PutUint32(hdr[5:], 0)
+			if _, err := bw.Write(hdr[:]); err != nil {
+				return err
+			}
+			continue
+		}
+		b, err := encodeValue(item.v)
+		if err != nil {
+			return fmt.Errorf("encode %v: %w", slotIPNet(item.slot, item.net), err)
+		}
+		if len(b) > MaxBinaryValueLen {
+			return fmt.Errorf("encode %v: value length %d exceeds %d", slotIPNet(item.slot, item.net), len(b), MaxBinaryValueLen)
+		}
+		binary.BigEndian.PutUint32(hdr[:4], uint32(item.net))
+		hdr[4] = byte(maskMaxLen - item.slot)
+		binary.BigEndian.PutUint32(hdr[5:], uint32(len(b)))
+		if _, err := bw.Write(hdr[:]); err != nil {
+			return err
+		}
+		if _, err := bw.Write(b); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadBinary read the table written by WriteBinary, the values are decoded by decodeValue
+func ReadBinary(r io.Reader, decodeValue func(b []byte) (interface{}, error)) (*routeTable, error) {
+	rt := NewRouteTable()
+	if err := rt.readBinary(r, decodeValue); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+func ReadBinaryOf[T any](r io.Reader, decodeValue func(b []byte) (T, error)) (*RouteTable[T], error) {
+	rt := NewRouteTableOf[T]()
+	if err := rt.readBinary(r, decodeValue); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+func (rt *RouteTable[T]) readBinary(r io.Reader, decodeValue func(b []byte) (T, error)) error {
+	br := bufio.NewReader(r)
+	var hdr [4 + 1 + 4]byte
+	if _, err := io.ReadFull(br, hdr[:4]); err != nil {
+		return err
+	}
+	count := binary.BigEndian.Uint32(hdr[:4])
+	var b []byte
+	for i := uint32(0); i < count; i++ {
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
+		}
+		slot, net, err := bitsSlot(binary.BigEndian.Uint32(hdr[:4]), int(hdr[4]&^binaryException))
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
+		}
+		n := binary.BigEndian.Uint32(hdr[5:])
+		if hdr[4]&binaryException != 0 {
+			if slot == defaultSlot || n != 0 {
+				return fmt.Errorf("entry %d: invalid exception %v", i, slotIPNet(slot, net))
+			}
+			if err := rt.addException(slot, net); err != nil {
+				return fmt.Errorf("entry %d: %w", i, err)
+			}
+			continue
+		}
+		if n > MaxBinaryValueLen {
+			return fmt.Errorf("entry %d: value length %d exceeds %d", i, n, MaxBinaryValueLen)
+		}
+		if uint32(cap(b)) < n {
+			b = make([]byte, n)
+		}
+		b = b[:n]
+		if _, err := io.ReadFull(br, b); err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
+		}
+		v, err := decodeValue(b)
+		if err != nil {
+			return fmt.Errorf("entry %d: decode %v: %w", i, slotIPNet(slot, net), err)
+		}
+		if _, _, err := rt.addRouteLimited(slot, net, v, addAlways); err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package route
+
+const (
+	compactMinSize = 64
+	compactRatio   = 4
+)
+
+// grown record the max len of rtHash, must be called with rte locked after inserting
+func (rte *rtEntry[T]) grown() {
+	if n := len(rte.rtHash); n > rte.peak {
+		rte.peak = n
+	}
+}
+
+// Compact rebuild the rtHash that has shrunk to less than 1/compactRatio of its max len,
+// since go map never release its buckets after deleting, the small one is switched to rtArray.
+// it copies the map under the slot lock, so it's O(n) and should be called sparingly, e.g. after a bulk delete
+func (rt *RouteTable[T]) Compact() {
+	for i := range rt.rts {
+		rte := &rt.rts[i]
+		rte.Lock()
+		if rte.peak >= compactMinSize && len(rte.rtHash) < rte.peak/compactRatio {
+			m := make(map[NetWork]T, len(rte.rtHash))
+			for net, v := range rte.rtHash {
+				m[net] = v
+			}
+			rte.fill(m)
+			rte.peak = len(m)
+		}
+		rte.Unlock()
+	}
+}
@@ -0,0 +1,43 @@
+package route
+
+import "net"
+
+// CoveringRoute return the longest route that contain network, excluding network itself,
+// e.g. 10.0.0.0/8 for 10.1.2.0/24 if both of them are in the table
+func (rt *RouteTable[T]) CoveringRoute(network string) (covering *net.IPNet, v T, ok bool) {
+	slot, net, err := parseNetwork(network)
+	if err != nil || slot == defaultSlot {
+		return nil, v, false
+	}
+
+	slot, net, v, ok = rt.lookupSlots(net, slot+1, defaultSlot)
+	if !ok {
+		return nil, v, false
+	}
+	return slotIPNet(slot, net), v, true
+}
+
+// Overlaps report whether network contain, is contained by, or equal any route in the table
+func (rt *RouteTable[T]) Overlaps(network string) bool {
+	slot, super, err := parseNetwork(network)
+	if err != nil {
+		return false
+	}
+	if _, _, _, ok := rt.lookupSlots(super, slot, defaultSlot); ok {
+		return true
+	}
+
+	superMask := NetWork(MaskForSlot(slot))
+	for i := 0; i < slot && i < maskMaxLen; i++ {
+		rte := &rt.rts[i]
+		rte.RLock()
+		for net := range rte.all() {
+			if net&superMask == super {
+				rte.RUnlock()
+				return true
+			}
+		}
+		rte.RUnlock()
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package route
+
+import (
+	"net"
+	"reflect"
+)
+
+type slotKey struct {
+	slot int
+	net  NetWork
+}
+
+func (rt *RouteTable[T]) items() []rtItem[T] {
+	var items []rtItem[T]
+	for i := 0; i <= defaultSlot; i++ {
+		items = append(items, rt.snapshot(i)...)
+	}
+	return items
+}
+
+// Diff compare rt with target, and return what to do to turn rt into target:
+// toAdd are the networks only in target, toDel are the networks only in rt,
+// changed are the networks in both but with different values, or an exception in only one of them.
+// eq compare the values, reflect.DeepEqual is used if eq is nil.
+// the networks are ordered from the longest mask to the shortest
+func (rt *RouteTable[T]) Diff(target *RouteTable[T], eq func(a, b T) bool) (toAdd, toDel, changed []*net.IPNet) {
+	if eq == nil {
+		eq = func(a, b T) bool { return reflect.DeepEqual(a, b) }
+	}
+
+	src, dst := rt.items(), target.items()
+	srcMap := make(map[slotKey]rtItem[T], len(src))
+	for _, item := range src {
+		srcMap[slotKey{item.slot, item.net}] = item
+	}
+	dstMap := make(map[slotKey]struct{}, len(dst))
+	for _, item := range dst {
+		key := slotKey{item.slot, item.net}
+		dstMap[key] = struct{}{}
+		if s, ok := srcMap[key]; !ok {
+			toAdd = append(toAdd, slotIPNet(item.slot, item.net))
+		} else if s.hole != item.hole || !eq(s.v, item.v) {
+			changed = append(changed, slotIPNet(item.slot, item.net))
+		}
+	}
+	for _, item := range src {
+		if _, ok := dstMap[slotKey{item.slot, item.net}]; !ok {
+			toDel = append(toDel, slotIPNet(item.slot, item.net))
+		}
+	}
+	return toAdd, toDel, changed
+}
+
+func (rt *routeTable) Diff(target *routeTable, eq func(a, b interface{}) bool) (toAdd, toDel, changed []*net.IPNet) {
+	return rt.RouteTable.Diff(&target.RouteTable, eq)
+}
+
+// Equal report whether rt and other have exactly the same networks with equal values,
+// eq compare the values, reflect.DeepEqual is used if eq is nil. an exception is only equal to an exception
+func (rt *RouteTable[T]) Equal(other *RouteTable[T], eq func(a, b T) bool) bool {
+	if eq == nil {
+		eq = func(a, b T) bool { return reflect.DeepEqual(a, b) }
+	}
+	if rt.Count() != other.Count() {
+		return false
+	}
+	for _, item := range rt.items() {
+		v, ok, hole := other.rawRoute(item.slot, item.net)
+		if !ok || hole != item.hole || !eq(item.v, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (rt *routeTable) Equal(other *routeTable, eq func(a, b interface{}) bool) bool {
+	return rt.RouteTable.Equal(&other.RouteTable, eq)
+}
+
+// rawRoute is getRoute without hiding the exceptions, which are stored with the zero value,
+// hole report whether the route is an exception
+func (rt *RouteTable[T]) rawRoute(slot int, net NetWork) (v T, ok, hole bool) {
+	if slot == defaultSlot {
+		v, ok = rt.getDefault()
+		return v, ok, false
+	}
+	rt.rts[slot].RLock()
+	v, ok = rt.rts[slot].get(net)
+	hole = rt.rts[slot].hole(net)
+	rt.rts[slot].RUnlock()
+	return v, ok, hole
+}
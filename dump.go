@@ -0,0 +1,87 @@
+package route
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+)
+
+// stringLimit is the max number of routes String print
+const stringLimit = 1024
+
+// Dump write one route per line, "network value" or "network except" for an exception,
+// from the longest mask to the shortest, and by network within the same mask length.
+// at most limit routes are written, limit <= 0 means no limit
+func (rt *RouteTable[T]) Dump(w io.Writer, limit int) error {
+	items := rt.items()
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].slot < items[j].slot || (items[i].slot == items[j].slot && items[i].net < items[j].net)
+	})
+
+	n := len(items)
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	for _, item := range items[:n] {
+		var err error
+		if item.hole {
+			_, err = fmt.Fprintf(w, "%v except\n", slotIPNet(item.slot, item.net))
+		} else {
+			_, err = fmt.Fprintf(w, "%v %v\n", slotIPNet(item.slot, item.net), item.v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if n < len(items) {
+		if _, err := fmt.Fprintf(w, "... %d more routes\n", len(items)-n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String dump the table for debugging, at most stringLimit routes are printed
+func (rt *RouteTable[T]) String() string {
+	var b strings.Builder
+	rt.Dump(&b, stringLimit)
+	return b.String()
+}
+
+// ExportByMask return the routes grouped by mask length, only the mask lengths that have routes
+// are in the map, and the routes of a mask length are ordered by network.
+// range the mask length from 32 to 0 to install the more specifics first.
+// an exception is exported with Exception set, it must be installed as a hole(e.g. unreachable)
+func (rt *RouteTable[T]) ExportByMask() map[int][]RouteEntryOf[T] {
+	m := make(map[int][]RouteEntryOf[T])
+	for i := 0; i <= defaultSlot; i++ {
+		items := rt.snapshot(i)
+		if len(items) == 0 {
+			continue
+		}
+		sort.Slice(items, func(a, b int) bool { return items[a].net < items[b].net })
+		entries := make([]RouteEntryOf[T], len(items))
+		for j, item := range items {
+			entries[j] = RouteEntryOf[T]{Network: slotIPNet(item.slot, item.net).String(), Value: item.v, Exception: item.hole}
+		}
+		m[maskMaxLen-i] = entries
+	}
+	return m
+}
+
+// WalkOrdered is Walk in a deterministic order, from the longest mask to the shortest,
+// and by network within the same mask length. it sort the copy of each slot before calling fn,
+// so it cost O(n log n) more than Walk and hold a whole slot in memory at once
+func (rt *RouteTable[T]) WalkOrdered(fn func(network *net.IPNet, v T) bool) {
+	for i := 0; i <= defaultSlot; i++ {
+		items := dropHoles(rt.snapshot(i))
+		sort.Slice(items, func(a, b int) bool { return items[a].net < items[b].net })
+		for _, item := range items {
+			if !fn(slotIPNet(item.slot, item.net), item.v) {
+				return
+			}
+		}
+	}
+}
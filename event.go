@@ -0,0 +1,74 @@
+package route
+
+import "net"
+
+type RouteEventType int
+
+const (
+	RouteAdd RouteEventType = iota
+	RouteDel
+	RouteReplace
+)
+
+type RouteEventOf[T any] struct {
+	Type    RouteEventType
+	Network *net.IPNet
+	Value   T //the new value for RouteAdd and RouteReplace, the removed value for RouteDel
+	//Exception report the route is an exception added by AddException, Value is the zero value.
+	//a RouteReplace with Exception false may replace an exception with a real route
+	Exception bool
+}
+
+type RouteEvent = RouteEventOf[interface{}]
+
+type routeHooks[T any] []func(evt RouteEventOf[T])
+
+func (hooks *routeHooks[T]) call(evt RouteEventOf[T]) {
+	for _, fn := range *hooks {
+		fn(evt)
+	}
+}
+
+// OnChange register fn to be notified after each successful mutation.
+// fn is called synchronously by the goroutine that modified the table, after all locks are released,
+// so it may call back into the table, but a slow fn slows down the mutations
+func (rt *RouteTable[T]) OnChange(fn func(evt RouteEventOf[T])) {
+	rt.hooksMu.Lock()
+	var hooks routeHooks[T]
+	if old := rt.hooks.Load(); old != nil {
+		hooks = append(hooks, *old...)
+	}
+	hooks = append(hooks, fn)
+	rt.hooks.Store(&hooks)
+	rt.hooksMu.Unlock()
+}
+
+func (rt *RouteTable[T]) notify(typ RouteEventType, slot int, net NetWork, v T, hole bool) {
+	hooks := rt.hooks.Load()
+	if hooks == nil {
+		return
+	}
+	hooks.call(RouteEventOf[T]{Type: typ, Network: slotIPNet(slot, net), Value: v, Exception: hole})
+}
+
+// pendingEvent is a change to notify after all locks are released, ok is false if nothing changed
+type pendingEvent[T any] struct {
+	typ  RouteEventType
+	slot int
+	net  NetWork
+	v    T
+	hole bool
+	ok   bool
+}
+
+func (rt *RouteTable[T]) emit(evt pendingEvent[T]) {
+	if evt.ok {
+		rt.notify(evt.typ, evt.slot, evt.net, evt.v, evt.hole)
+	}
+}
+
+func (rt *RouteTable[T]) emitAll(evts []pendingEvent[T]) {
+	for _, evt := range evts {
+		rt.emit(evt)
+	}
+}
@@ -0,0 +1,61 @@
+package route
+
+import (
+	"fmt"
+	"time"
+)
+
+// AddException add network as a hole: RouteLookup of an address inside it return not found,
+// even if a shorter route would match, e.g. route 10.0.0.0/8 except 10.1.2.0/24.
+// the exception is stored as a route with the zero value, so it's counted by Count, but Walk,
+// the iterators, FindByValue and the other walkers skip it like RangeScan, use IsException to check it.
+// AddRoute or DelRoute on network replace or remove the exception
+func (rt *RouteTable[T]) AddException(network string) error {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		return err
+	}
+	if slot == defaultSlot {
+		return fmt.Errorf("%w: can't add exception for the default route", ErrInvalidMask)
+	}
+	return rt.addException(slot, net)
+}
+
+// addException store the exception of net in slot, slot must not be the defaultSlot.
+// it's limited like AddRoute, an exception counts as a route
+func (rt *RouteTable[T]) addException(slot int, net NetWork) error {
+	_, _, err := rt.modifyLimited(slot, net, time.Time{}, func(old T, existed bool) (T, routeOp) {
+		var zero T
+		return zero, opHole
+	})
+	return err
+}
+
+// IsException report whether network is an exception added by AddException
+func (rt *RouteTable[T]) IsException(network string) bool {
+	slot, net, err := parseNetwork(network)
+	if err != nil || slot == defaultSlot {
+		return false
+	}
+	rte := &rt.rts[slot]
+	rte.RLock()
+	hole := rte.hole(net)
+	rte.RUnlock()
+	return hole
+}
+
+// hole and addHole must be called with rte locked
+func (rte *rtEntry[T]) hole(net NetWork) bool {
+	if rte.holes == nil {
+		return false
+	}
+	_, ok := rte.holes[net]
+	return ok
+}
+
+func (rte *rtEntry[T]) addHole(net NetWork) {
+	if rte.holes == nil {
+		rte.holes = make(map[NetWork]struct{})
+	}
+	rte.holes[net] = struct{}{}
+}
@@ -0,0 +1,42 @@
+package route
+
+import "expvar"
+
+// expvarStats is the counters published by PublishExpvar
+type expvarStats struct {
+	routes  *expvar.Int
+	lookups *expvar.Int
+	hits    *expvar.Int
+}
+
+// PublishExpvar publish the table size and the RouteLookup counters as the expvar.Int
+// name.routes, name.lookups and name.hits, hits is the lookups that matched a route.
+// name.routes start from Count and is kept by an OnChange hook, so publish it before the table
+// is modified concurrently, or the changes during the call may be counted twice.
+// it panic if the names are already published, like expvar.NewInt
+func (rt *RouteTable[T]) PublishExpvar(name string) {
+	vs := &expvarStats{
+		routes:  expvar.NewInt(name + ".routes"),
+		lookups: expvar.NewInt(name + ".lookups"),
+		hits:    expvar.NewInt(name + ".hits"),
+	}
+	rt.OnChange(func(evt RouteEventOf[T]) {
+		switch evt.Type {
+		case RouteAdd:
+			vs.routes.Add(1)
+		case RouteDel:
+			vs.routes.Add(-1)
+		}
+	})
+	vs.routes.Set(int64(rt.Count()))
+	rt.vars.Store(vs)
+}
+
+func (rt *RouteTable[T]) recordLookup(ok bool) {
+	if vs := rt.vars.Load(); vs != nil {
+		vs.lookups.Add(1)
+		if ok {
+			vs.hits.Add(1)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package route
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// IPRoute is the value stored by LoadFromIPRoute
+type IPRoute struct {
+	Via net.IP //nil if the route is directly connected
+	Dev string
+}
+
+// LoadFromIPRoute parse the output of `ip route` like
+//
+//	default via 192.168.1.1 dev eth0 proto dhcp metric 100
+//	10.0.0.0/8 via 192.168.1.1 dev eth0
+//	192.168.1.0/24 dev eth0 proto kernel scope link src 192.168.1.10
+//
+// and add each route with IPRoute as the value, it return the number of routes loaded.
+// "default" is loaded as 0.0.0.0/0 if its gateway is ipv4 or absent, ipv6 routes(including the default route
+// via an ipv6 gateway) and the typed routes(local, blackhole ...) are skipped
+func (rt *routeTable) LoadFromIPRoute(r io.Reader) (int, error) {
+	n := 0
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		network, v, ok := parseIPRoute(scanner.Text())
+		if !ok {
+			continue
+		}
+		err := rt.AddRoute(network, v)
+		if errors.Is(err, ErrNotIPv4) {
+			continue
+		}
+		if err != nil {
+			return n, fmt.Errorf("line %d: %w", line, err)
+		}
+		n++
+	}
+	return n, scanner.Err()
+}
+
+func parseIPRoute(line string) (string, IPRoute, bool) {
+	var v IPRoute
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return "", v, false
+	}
+	if fields[0] == "unicast" {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return "", v, false
+	}
+
+	network := fields[0]
+	switch {
+	case network == "default":
+		network = "0.0.0.0/0" //unless the gateway is ipv6, checked below
+	case strings.Contains(network, ":"):
+		return "", v, false
+	case net.ParseIP(network) != nil:
+		network += "/32"
+	case !strings.Contains(network, "/"):
+		return "", v, false //local, broadcast, blackhole ...
+	}
+
+	for i := 1; i+1 < len(fields); i++ {
+		switch fields[i] {
+		case "via":
+			if (fields[i+1] == "inet" || fields[i+1] == "inet6") && i+2 < len(fields) {
+				i++ //via inet6 fe80::1
+			}
+			v.Via = net.ParseIP(fields[i+1])
+			i++
+		case "dev":
+			v.Dev = fields[i+1]
+			i++
+		}
+	}
+	if fields[0] == "default" && v.Via != nil && v.Via.To4() == nil {
+		return "", v, false //the ipv6 default route of `ip -6 route` or `ip route show table all`
+	}
+	return network, v, true
+}
@@ -0,0 +1,55 @@
+package route
+
+import (
+	"context"
+	"net"
+)
+
+// RouteIteratorOf return the routes one by one from the longest mask to the shortest.
+// the routes of a slot are copied when the iterator reaches it, so the table can be
+// modified during the iteration: a slot already copied is not affected by the modification
+type RouteIteratorOf[T any] struct {
+	rt    *RouteTable[T]
+	slot  int
+	items []rtItem[T]
+}
+
+type RouteIterator = RouteIteratorOf[interface{}]
+
+func (rt *RouteTable[T]) NewIterator() *RouteIteratorOf[T] {
+	return &RouteIteratorOf[T]{rt: rt}
+}
+
+// Next return the next route, ok is false if there are no more routes
+func (it *RouteIteratorOf[T]) Next() (network *net.IPNet, v T, ok bool) {
+	for len(it.items) == 0 {
+		if it.slot > defaultSlot {
+			return nil, v, false
+		}
+		it.items = dropHoles(it.rt.snapshot(it.slot))
+		it.slot++
+	}
+	item := it.items[0]
+	it.items = it.items[1:]
+	return slotIPNet(item.slot, item.net), item.v, true
+}
+
+// Stream send the routes to the returned channel from the longest mask to the shortest,
+// the channel is closed after all routes are sent or ctx is done.
+// each slot is copied under its lock and sent without lock, so a slow consumer doesn't block mutations
+func (rt *RouteTable[T]) Stream(ctx context.Context) <-chan RouteEntryOf[T] {
+	ch := make(chan RouteEntryOf[T])
+	go func() {
+		defer close(ch)
+		for i := 0; i <= defaultSlot; i++ {
+			for _, item := range dropHoles(rt.snapshot(i)) {
+				select {
+				case ch <- RouteEntryOf[T]{Network: slotIPNet(item.slot, item.net).String(), Value: item.v}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,41 @@
+package route
+
+import (
+	"encoding/json"
+	"net"
+)
+
+// MarshalJSON encode the table as a list of {"network": "10.0.0.0/8", "value": ...},
+// the value is encoded by encoding/json directly, an exception is {"network": ..., "value": zero, "exception": true}
+func (rt *RouteTable[T]) MarshalJSON() ([]byte, error) {
+	routes := []RouteEntryOf[T]{}
+	for _, item := range rt.items() {
+		routes = append(routes, RouteEntryOf[T]{Network: slotIPNet(item.slot, item.net).String(), Value: item.v, Exception: item.hole})
+	}
+	return json.Marshal(routes)
+}
+
+// UnmarshalJSON add the routes encoded by MarshalJSON to rt, rt must be created by NewRouteTable.
+// the value is decoded as T, so for interface{} value it's what encoding/json gives
+// (map[string]interface{}, float64 ...) rather than the original type
+func (rt *RouteTable[T]) UnmarshalJSON(data []byte) error {
+	var routes []RouteEntryOf[T]
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return err
+	}
+	for _, r := range routes {
+		_, ipnet, err := net.ParseCIDR(r.Network)
+		if err != nil {
+			return err
+		}
+		if r.Exception {
+			err = rt.AddException(r.Network)
+		} else {
+			err = rt.AddRouteNet(ipnet, r.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
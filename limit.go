@@ -0,0 +1,244 @@
+package route
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EvictPolicy decide what to do when a new route is added to the table that reach its limit
+type EvictPolicy int
+
+const (
+	EvictNone           EvictPolicy = iota //reject the new route with ErrTableFull
+	EvictShortestPrefix                    //remove a route of the shortest mask, the default route first
+	EvictOldest                            //remove the route added the earliest
+)
+
+// limiter bound the number of routes, all the changes that may add a network to a limited table
+// are serialized by mu and checked, including the bulk ones like AddRoutes and ReplaceMaskLevel
+type limiter struct {
+	mu     sync.Mutex
+	max    atomic.Int64 //0 means no limit
+	policy EvictPolicy
+
+	//for EvictOldest, the routes in the order they were added.
+	//an entry of queue is stale if its seq is not the one in added
+	seq   uint64
+	added map[slotKey]uint64
+	queue []limitItem
+}
+
+type limitItem struct {
+	key slotKey
+	seq uint64
+}
+
+func NewRouteTableWithLimit(max int, policy EvictPolicy) *routeTable {
+	rt := NewRouteTable()
+	rt.limit.policy = policy
+	rt.SetLimit(max)
+	return rt
+}
+
+func NewRouteTableWithLimitOf[T any](max int, policy EvictPolicy) *RouteTable[T] {
+	rt := NewRouteTableOf[T]()
+	rt.limit.policy = policy
+	rt.SetLimit(max)
+	return rt
+}
+
+// SetLimit change the max number of routes, max <= 0 means no limit.
+// the routes over the new limit are not removed until the next add.
+// for EvictOldest, the routes added while rt was not limited are taken as the oldest
+func (rt *RouteTable[T]) SetLimit(max int) {
+	if max < 0 {
+		max = 0
+	}
+	lim := &rt.limit
+	lim.mu.Lock()
+	unlimited := lim.max.Load() == 0
+	lim.max.Store(int64(max))
+	if unlimited && max > 0 && lim.policy == EvictOldest {
+		rt.resetOldest(nil)
+	}
+	lim.mu.Unlock()
+}
+
+// copyLimit copy the limit of rt to the new table c after the routes are copied
+func (rt *RouteTable[T]) copyLimit(c *RouteTable[T]) {
+	lim := &rt.limit
+	lim.mu.Lock()
+	max, order := lim.max.Load(), rt.liveOldest()
+	lim.mu.Unlock()
+
+	c.limit.mu.Lock()
+	c.limit.policy = lim.policy
+	c.limit.max.Store(max)
+	if max > 0 && lim.policy == EvictOldest {
+		c.resetOldest(order)
+	}
+	c.limit.mu.Unlock()
+}
+
+// liveOldest return the routes of the EvictOldest queue from the oldest, must be called with limit.mu locked
+func (rt *RouteTable[T]) liveOldest() []slotKey {
+	lim := &rt.limit
+	var keys []slotKey
+	for _, item := range lim.queue {
+		if lim.added[item.key] == item.seq {
+			keys = append(keys, item.key)
+		}
+	}
+	return keys
+}
+
+// resetOldest rebuild the EvictOldest queue from the routes of rt, must be called with limit.mu locked.
+// the routes in order keep their order, the others were added while rt was not limited
+// and are taken as older than them
+func (rt *RouteTable[T]) resetOldest(order []slotKey) {
+	lim := &rt.limit
+	lim.added, lim.queue = nil, nil
+	known := make(map[slotKey]struct{}, len(order))
+	for _, key := range order {
+		known[key] = struct{}{}
+	}
+	for _, item := range rt.items() {
+		key := slotKey{item.slot, item.net}
+		if _, ok := known[key]; !ok {
+			rt.pushOldest(key)
+		}
+	}
+	for _, key := range order {
+		if _, ok, _ := rt.rawRoute(key.slot, key.net); ok {
+			rt.pushOldest(key)
+		}
+	}
+}
+
+// Limit return the max number of routes, 0 means no limit
+func (rt *RouteTable[T]) Limit() int {
+	return int(rt.limit.max.Load())
+}
+
+// addRouteLimited is addRoute that enforce the limit, see modifyLimited
+func (rt *RouteTable[T]) addRouteLimited(slot int, net NetWork, v T, mode addMode) (T, bool, error) {
+	if mode == addIfExist {
+		old, existed := rt.addRoute(slot, net, v, mode) //never add a network
+		return old, existed, nil
+	}
+	return rt.modifyLimited(slot, net, time.Time{}, addFn(v, mode))
+}
+
+// modifyLimited is modifyTTL that enforce the limit: if the network doesn't exist, routes are evicted
+// by the policy to make room for it, or ErrTableFull is returned without calling fn.
+// it count the routes on each change of a new network, so a limited table pay for a Count per add.
+// the hooks are called after lim.mu is unlocked, so a hook may add routes to rt
+func (rt *RouteTable[T]) modifyLimited(slot int, net NetWork, at time.Time, fn func(old T, existed bool) (T, routeOp)) (T, bool, error) {
+	if rt.limit.max.Load() == 0 {
+		old, existed := rt.modifyTTL(slot, net, at, fn)
+		return old, existed, nil
+	}
+
+	lim := &rt.limit
+	var evicted []pendingEvent[T]
+	lim.mu.Lock()
+	if _, existed, _ := rt.rawRoute(slot, net); !existed {
+		for max := int(lim.max.Load()); max > 0 && rt.Count() >= max; {
+			evt, ok := rt.evict()
+			if !ok {
+				lim.mu.Unlock()
+				rt.emitAll(evicted)
+				var zero T
+				return zero, false, ErrTableFull
+			}
+			evicted = append(evicted, evt)
+		}
+	}
+	old, existed, evt := rt.modifyEvent(slot, net, at, fn)
+	rt.limitAdded(evt)
+	lim.mu.Unlock()
+	rt.emitAll(evicted)
+	rt.emit(evt)
+	return old, existed, nil
+}
+
+// limitAdded record the network added by evt for EvictOldest, must be called with limit.mu locked
+func (rt *RouteTable[T]) limitAdded(evt pendingEvent[T]) {
+	if evt.ok && evt.typ == RouteAdd && rt.limit.policy == EvictOldest && rt.limit.max.Load() != 0 {
+		rt.pushOldest(slotKey{evt.slot, evt.net})
+	}
+}
+
+// evict remove one route according to the policy, must be called with limit.mu locked.
+// it return false if nothing can be removed, the removal is returned to be notified after unlocked
+func (rt *RouteTable[T]) evict() (pendingEvent[T], bool) {
+	switch rt.limit.policy {
+	case EvictShortestPrefix:
+		if ok, evt := rt.delRouteEvent(defaultSlot, 0); ok {
+			return evt, true
+		}
+		for mask := rt.slotMask.Load(); mask != 0; {
+			slot := bits.Len32(mask) - 1
+			if net, ok := rt.anyRoute(slot); ok {
+				if ok, evt := rt.delRouteEvent(slot, net); ok {
+					return evt, true
+				}
+			}
+			mask &^= 1 << uint32(slot)
+		}
+	case EvictOldest:
+		lim := &rt.limit
+		for len(lim.queue) > 0 {
+			item := lim.queue[0]
+			lim.queue = lim.queue[1:]
+			if lim.added[item.key] != item.seq {
+				continue
+			}
+			delete(lim.added, item.key)
+			if ok, evt := rt.delRouteEvent(item.key.slot, item.key.net); ok {
+				return evt, true
+			}
+		}
+	}
+	return pendingEvent[T]{}, false
+}
+
+// anyRoute return a network of slot
+func (rt *RouteTable[T]) anyRoute(slot int) (NetWork, bool) {
+	rte := &rt.rts[slot]
+	rte.RLock()
+	defer rte.RUnlock()
+	for net := range rte.all() {
+		return net, true
+	}
+	return 0, false
+}
+
+// pushOldest record key as the newest route, must be called with limit.mu locked.
+// the queue is compacted when it grow to twice the limit, dropping the stale entries
+// and the routes deleted by DelRoute meanwhile
+func (rt *RouteTable[T]) pushOldest(key slotKey) {
+	lim := &rt.limit
+	if lim.added == nil {
+		lim.added = make(map[slotKey]uint64)
+	}
+	lim.seq++
+	lim.added[key] = lim.seq
+	lim.queue = append(lim.queue, limitItem{key, lim.seq})
+	if len(lim.queue) > 2*int(lim.max.Load())+64 {
+		live := make([]limitItem, 0, len(lim.added))
+		for _, item := range lim.queue {
+			if lim.added[item.key] != item.seq {
+				continue
+			}
+			if _, ok, _ := rt.rawRoute(item.key.slot, item.key.net); !ok {
+				delete(lim.added, item.key)
+				continue
+			}
+			live = append(live, item)
+		}
+		lim.queue = live
+	}
+}
@@ -0,0 +1,42 @@
+package route
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// Merge add all routes of other to rt, if a network exists in both tables,
+// onConflict pick the value from a(the value in rt) and b(the value in other).
+// an exception of other replace the route of rt, and a route of other replace the exception of rt,
+// like AddException and AddRoute do, onConflict is not called for them.
+// other is copied slot by slot, so it's safe to modify other meanwhile.
+// onConflict is called with the slot of rt locked, it must not call back into rt.
+// if rt is limited, the routes are added through the limiter, Merge stop at the first one
+// rejected with ErrTableFull and return it, the routes merged before are kept
+func (rt *RouteTable[T]) Merge(other *RouteTable[T], onConflict func(network *net.IPNet, a, b T) T) error {
+	for i := 0; i <= defaultSlot; i++ {
+		for _, item := range other.snapshot(i) {
+			var err error
+			if item.hole {
+				err = rt.addException(item.slot, item.net)
+			} else {
+				b := item.v
+				_, _, err = rt.modifyLimited(item.slot, item.net, time.Time{}, func(a T, existed bool) (T, routeOp) {
+					if existed && (item.slot == defaultSlot || !rt.rts[item.slot].hole(item.net)) {
+						return onConflict(slotIPNet(item.slot, item.net), a, b), opStore
+					}
+					return b, opStore
+				})
+			}
+			if err != nil {
+				return fmt.Errorf("%v: %w", slotIPNet(item.slot, item.net), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (rt *routeTable) Merge(other *routeTable, onConflict func(network *net.IPNet, a, b interface{}) interface{}) error {
+	return rt.RouteTable.Merge(&other.RouteTable, onConflict)
+}
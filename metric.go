@@ -0,0 +1,22 @@
+package route
+
+// RouteMetric is stored as the value by AddRouteMetric, so RouteLookup return RouteMetric for these routes
+type RouteMetric struct {
+	Value  interface{}
+	Metric int
+}
+
+// AddRouteMetric add the route with a metric, the caller can implement administrative distance by the metric
+func (rt *routeTable) AddRouteMetric(network string, v interface{}, metric int) error {
+	return rt.AddRoute(network, RouteMetric{Value: v, Metric: metric})
+}
+
+// RouteLookupMetric return the value and the metric of the matched route,
+// the metric is 0 if the route is not added by AddRouteMetric
+func (rt *routeTable) RouteLookupMetric(ip NetWork) (v interface{}, metric int, ok bool) {
+	v, ok = rt.RouteLookupOK(ip)
+	if rm, isMetric := v.(RouteMetric); isMetric {
+		return rm.Value, rm.Metric, ok
+	}
+	return v, 0, ok
+}
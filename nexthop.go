@@ -0,0 +1,31 @@
+package route
+
+import "net"
+
+// NextHop can be implemented by the stored values, so RouteLookupNextHop can return them typed.
+// it's optional, the values not implementing it are stored and looked up as usual
+type NextHop interface {
+	Addr() net.IP //nil if the route is directly connected
+	Iface() string
+}
+
+var _ NextHop = IPRoute{}
+
+func (r IPRoute) Addr() net.IP {
+	return r.Via
+}
+
+func (r IPRoute) Iface() string {
+	return r.Dev
+}
+
+// RouteLookupNextHop lookup ip and return the value as NextHop,
+// ok is false if there is no route matched or the value doesn't implement NextHop
+func (rt *routeTable) RouteLookupNextHop(ip NetWork) (NextHop, bool) {
+	v, ok := rt.RouteLookupOK(ip)
+	if !ok {
+		return nil, false
+	}
+	nh, ok := v.(NextHop)
+	return nh, ok
+}
@@ -0,0 +1,28 @@
+package route
+
+type RouteTableOpts struct {
+	//FreeEmptyMaps free the rtArray of a mask length when its last route is deleted,
+	//and make it again on the next add. it saves memory for the table that cycles through
+	//many mask lengths, but the table that churns a single mask length pays for reallocating
+	FreeEmptyMaps bool
+}
+
+func NewRouteTableOpts(opts RouteTableOpts) *routeTable {
+	rt := new(routeTable)
+	rt.freeEmpty = opts.FreeEmptyMaps
+	return rt
+}
+
+func NewRouteTableOptsOf[T any](opts RouteTableOpts) *RouteTable[T] {
+	rt := new(RouteTable[T])
+	rt.freeEmpty = opts.FreeEmptyMaps
+	return rt
+}
+
+// freeHash free the storage of the emptied slot if FreeEmptyMaps, must be called with rte locked
+func (rt *RouteTable[T]) freeHash(rte *rtEntry[T]) {
+	if rt.freeEmpty {
+		rte.rtHash, rte.rtKeys, rte.rtVals = nil, nil, nil
+		rte.holes, rte.peak = nil, 0
+	}
+}
@@ -0,0 +1,60 @@
+package route
+
+import "time"
+
+// PathTable store several values(paths) for one network, it's useful for ECMP.
+// the paths slice is never modified after stored, so the returned paths can be read without lock
+type PathTable[T any] struct {
+	RouteTable[[]T]
+}
+
+func NewPathTable() *PathTable[interface{}] {
+	return NewPathTableOf[interface{}]()
+}
+
+func NewPathTableOf[T any]() *PathTable[T] {
+	return new(PathTable[T])
+}
+
+// AddPath append v to the paths of the network
+func (pt *PathTable[T]) AddPath(network string, v T) error {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		return err
+	}
+	_, _, err = pt.modifyLimited(slot, net, time.Time{}, func(old []T, existed bool) ([]T, routeOp) {
+		paths := make([]T, len(old), len(old)+1)
+		copy(paths, old)
+		return append(paths, v), opStore
+	})
+	return err
+}
+
+// DelPath remove the first path equal to v, the network is deleted when its last path is removed
+func (pt *PathTable[T]) DelPath(network string, v T, eq func(a, b T) bool) error {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		return err
+	}
+	pt.modify(slot, net, func(old []T, existed bool) ([]T, routeOp) {
+		for i := range old {
+			if eq(old[i], v) {
+				if len(old) == 1 {
+					return old, opDelete
+				}
+				paths := make([]T, 0, len(old)-1)
+				paths = append(paths, old[:i]...)
+				paths = append(paths, old[i+1:]...)
+				return paths, opStore
+			}
+		}
+		return old, opNone
+	})
+	return nil
+}
+
+// RouteLookupPaths return all paths of the longest matched network, the caller must not modify it
+func (pt *PathTable[T]) RouteLookupPaths(ip NetWork) []T {
+	paths, _ := pt.RouteLookup(ip)
+	return paths
+}
@@ -0,0 +1,125 @@
+package route
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+/*
+策略路由: 按(目的网段, 源网段)选路。每个目的网段对应一个按源地址查找的路由表，
+所有这些目的网段也放在一个RouteTable 里，值就是对应的源地址路由表，所以两级都是最长匹配。
+目的网段的表用RouteTable[interface{}], 如果用RouteTable[*RouteTable[T]] 泛型实例化会无限递归。
+策略路由和AddRoute 添加的普通路由分开存放，普通路由相当于源地址是任意(ANY)的策略路由，
+RouteLookup 等原来的接口只查普通路由，Count, Walk, Clone 等也看不到策略路由。
+SetLimit 的上限对策略路由单独计数, 策略路由数达到上限后 AddRoutePolicy 返回 ErrTableFull, 不会淘汰。
+Clear 也清空策略路由。
+*/
+type policyRoutes[T any] struct {
+	mu  sync.Mutex                              //serialize AddRoutePolicy and DelRoutePolicy
+	dst atomic.Pointer[RouteTable[interface{}]] //the values are *RouteTable[T], nil if there is no policy route
+	n   int                                     //the number of policy routes, guarded by mu
+}
+
+// AddRoutePolicy add the route of v for the packets from src to dst.
+// on a limited table it return ErrTableFull if the policy routes reach the limit, they're never evicted
+func (rt *RouteTable[T]) AddRoutePolicy(dst, src string, v T) error {
+	dstSlot, dstNet, err := parseNetwork(dst)
+	if err != nil {
+		return err
+	}
+	srcSlot, srcNet, err := parseNetwork(src)
+	if err != nil {
+		return err
+	}
+
+	rt.policy.mu.Lock()
+	defer rt.policy.mu.Unlock()
+	dsts := rt.policy.dst.Load()
+	if dsts == nil {
+		dsts = NewRouteTableOf[interface{}]()
+		rt.policy.dst.Store(dsts)
+	}
+	var srcs *RouteTable[T]
+	sv, ok := dsts.getRoute(dstSlot, dstNet)
+	if ok {
+		srcs = sv.(*RouteTable[T])
+	}
+	existed := false
+	if srcs != nil {
+		_, existed = srcs.getRoute(srcSlot, srcNet)
+	}
+	if max := int(rt.limit.max.Load()); !existed && max > 0 && rt.policy.n >= max {
+		return ErrTableFull
+	}
+	if srcs == nil {
+		srcs = NewRouteTableOf[T]()
+		dsts.addRoute(dstSlot, dstNet, srcs, addAlways)
+	}
+	srcs.addRoute(srcSlot, srcNet, v, addAlways)
+	if !existed {
+		rt.policy.n++
+	}
+	return nil
+}
+
+// DelRoutePolicy delete the route added by AddRoutePolicy
+func (rt *RouteTable[T]) DelRoutePolicy(dst, src string) error {
+	dstSlot, dstNet, err := parseNetwork(dst)
+	if err != nil {
+		return err
+	}
+	srcSlot, srcNet, err := parseNetwork(src)
+	if err != nil {
+		return err
+	}
+
+	rt.policy.mu.Lock()
+	defer rt.policy.mu.Unlock()
+	dsts := rt.policy.dst.Load()
+	if dsts == nil {
+		return nil
+	}
+	if sv, ok := dsts.getRoute(dstSlot, dstNet); ok {
+		srcs := sv.(*RouteTable[T])
+		if srcs.delRoute(srcSlot, srcNet) {
+			rt.policy.n--
+		}
+		if srcs.Count() == 0 {
+			dsts.delRoute(dstSlot, dstNet)
+		}
+	}
+	return nil
+}
+
+// RouteLookupPolicy match the longest dst first, then the longest src among the policy routes of that dst.
+// if no src matched, the ordinary route of the same dst is used as the route of ANY source,
+// otherwise the shorter dst are tried in turn. it's RouteLookupOK if there is no policy route
+func (rt *RouteTable[T]) RouteLookupPolicy(dstIP, srcIP NetWork) (T, bool) {
+	dsts := rt.policy.dst.Load()
+	if dsts == nil {
+		return rt.RouteLookupOK(dstIP)
+	}
+
+	slot, _, v, ok := rt.lookup(dstIP)
+	var pv T
+	var pok bool
+	dsts.match(dstIP, func(pslot int, _ NetWork, srcs interface{}) bool {
+		if ok && slot < pslot {
+			return false //the ordinary route is more specific
+		}
+		pv, pok = srcs.(*RouteTable[T]).RouteLookupOK(srcIP)
+		return !pok
+	})
+	if pok {
+		return pv, true
+	}
+	return v, ok
+}
+
+// clearPolicy drop all policy routes
+func (rt *RouteTable[T]) clearPolicy() {
+	rt.policy.mu.Lock()
+	rt.policy.dst.Store(nil)
+	rt.policy.n = 0
+	rt.policy.mu.Unlock()
+}
@@ -0,0 +1,27 @@
+package route
+
+import "net"
+
+// RangeScan return the routes whose network address is in [start, end] whatever the mask is,
+// from the longest mask to the shortest. it's not the containment, e.g. 10.0.0.0/8 is in
+// [10.0.0.0, 10.0.0.255], but 9.0.0.0/7 is not though it contain the range
+func (rt *RouteTable[T]) RangeScan(start, end NetWork) []*net.IPNet {
+	var nets []*net.IPNet
+	for i := 0; i < maskMaxLen; i++ {
+		if rt.slotMask.Load()&(1<<uint32(i)) == 0 {
+			continue
+		}
+		rte := &rt.rts[i]
+		rte.RLock()
+		for net := range rte.all() {
+			if net >= start && net <= end && !rte.hole(net) {
+				nets = append(nets, slotIPNet(i, net))
+			}
+		}
+		rte.RUnlock()
+	}
+	if _, ok := rt.getDefault(); ok && start == 0 {
+		nets = append(nets, slotIPNet(defaultSlot, 0))
+	}
+	return nets
+}
@@ -0,0 +1,125 @@
+package route
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// slotStorage is the storage of one slot that BenchmarkSlotStorage compare
+type slotStorage interface {
+	get(net NetWork) (int, bool)
+	set(net NetWork, v int)
+}
+
+type mutexSlot struct {
+	mu  sync.RWMutex
+	rte rtEntry[int]
+}
+
+func (s *mutexSlot) get(net NetWork) (int, bool) {
+	s.mu.RLock()
+	v, ok := s.rte.get(net)
+	s.mu.RUnlock()
+	return v, ok
+}
+
+func (s *mutexSlot) set(net NetWork, v int) {
+	s.mu.Lock()
+	s.rte.set(net, v)
+	s.mu.Unlock()
+}
+
+type rcuSlot struct {
+	mu sync.Mutex
+	m  atomic.Pointer[map[NetWork]int]
+}
+
+func (s *rcuSlot) get(net NetWork) (int, bool) {
+	v, ok := (*s.m.Load())[net]
+	return v, ok
+}
+
+func (s *rcuSlot) set(net NetWork, v int) {
+	s.mu.Lock()
+	old := *s.m.Load()
+	m := make(map[NetWork]int, len(old)+1)
+	for k, v := range old {
+		m[k] = v
+	}
+	m[net] = v
+	s.m.Store(&m)
+	s.mu.Unlock()
+}
+
+type syncMapSlot struct {
+	m sync.Map
+}
+
+func (s *syncMapSlot) get(net NetWork) (int, bool) {
+	v, ok := s.m.Load(net)
+	if !ok {
+		return 0, false
+	}
+	return v.(int), true
+}
+
+func (s *syncMapSlot) set(net NetWork, v int) {
+	s.m.Store(net, v)
+}
+
+// BenchmarkSlotStorage run 16 readers and 1 writer on a slot of 1000 routes, ns/op is per lookup
+func BenchmarkSlotStorage(b *testing.B) {
+	const readers, routes = 16, 1000
+	newRCU := func() slotStorage {
+		s := new(rcuSlot)
+		s.m.Store(&map[NetWork]int{})
+		return s
+	}
+	variants := []struct {
+		name string
+		new  func() slotStorage
+	}{
+		{"mutex", func() slotStorage { return new(mutexSlot) }},
+		{"rcu", newRCU},
+		{"syncmap", func() slotStorage { return new(syncMapSlot) }},
+	}
+	for _, tt := range variants {
+		b.Run(tt.name, func(b *testing.B) {
+			s := tt.new()
+			for i := 0; i < routes; i++ {
+				s.set(NetWork(i)<<8, i)
+			}
+			stop := make(chan struct{})
+			var writer sync.WaitGroup
+			writer.Add(1)
+			go func() {
+				defer writer.Done()
+				for i := 0; ; i++ {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					s.set(NetWork(i%routes)<<8, i)
+				}
+			}()
+
+			b.ResetTimer()
+			var wg sync.WaitGroup
+			for r := 0; r < readers; r++ {
+				wg.Add(1)
+				go func(r int) {
+					defer wg.Done()
+					for i := r; i < b.N; i += readers {
+						s.get(NetWork(i%routes) << 8)
+					}
+				}(r)
+			}
+			wg.Wait()
+			b.StopTimer()
+			close(stop)
+			writer.Wait()
+		})
+	}
+}
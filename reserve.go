@@ -0,0 +1,26 @@
+package route
+
+// Reserve pre-allocate the rtHash of maskLen for n routes to avoid rehashing on bulk load,
+// the routes already in the slot are kept. maskLen 0 has only one route, and n <= arrayMax
+// fit in the rtArray, nothing to reserve
+func (rt *RouteTable[T]) Reserve(maskLen int, n int) error {
+	slot, err := SlotForMask(maskLen)
+	if err != nil {
+		return err
+	}
+	if slot == defaultSlot || n <= arrayMax {
+		return nil
+	}
+
+	rte := &rt.rts[slot]
+	rte.Lock()
+	if rte.count() < n {
+		m := make(map[NetWork]T, n)
+		for net, v := range rte.all() {
+			m[net] = v
+		}
+		rte.rtHash, rte.rtKeys, rte.rtVals = m, nil, nil
+	}
+	rte.Unlock()
+	return nil
+}
@@ -1,9 +1,14 @@
 package route
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 /*
@@ -28,106 +33,989 @@ uint32|masklen32|maskLen31|maskLen30......
 [2]{maskLen:16, rtNum:xxx ,rtHash:xxx, rtAarry:xxx}
 [3]{maskLen:0, rtNum:xxx ,rtHash:xxx, rtAarry:xxx}
 如果路由条目的数量rtNum 小于某个数值(比如4)那么就把路由条目放在rtAarry数组，如果rtNum超过一定的数值，就用哈希表rtHash来存储路由条目
+
+和routev2 的区别: 查找路由时不加表级别的锁，slotMask 是原子读取的，每个掩码长度的槽有自己的读写锁，
+所以读写不同掩码长度的路由不会互相阻塞，锁的粒度比routev2 的分段锁更细，代价是查找时每个有路由的槽都要加一次读锁，
+而routev2 一个分段只加一次锁。路由条目少、掩码长度分散的时候用这个实现，掩码长度集中、查找很频繁的时候用routev2.
 */
 const (
-	maskMaxLen = 32
-	first      = 4
-	second     = 8
+	maskMaxLen  = 32
+	defaultSlot = maskMaxLen
+	first       = 4
+	second      = 8
+
+	ctxCheckSlots = 8 //RouteLookupCtx check the ctx every ctxCheckSlots probed slots
 )
 
 type NetWork uint32
-type routeTable struct {
+
+var (
+	ErrRouteNotFound = errors.New("route not found")
+	ErrNotIPv4       = errors.New("not ipv4")
+	ErrInvalidMask   = errors.New("invalid mask")
+	ErrTableFull     = errors.New("route table is full")
+)
+
+// RouteTable 用泛型存储路由的值，避免interface{} 的装箱和类型断言
+type RouteTable[T any] struct {
 	sync.RWMutex
-	slotMask uint32 //如何根据rtHash 的是否有路由条目来设置 slotMask, 加锁？
-	rts      [maskMaxLen]rtEntry
+	slotMask atomic.Uint32 //只在持有对应rtEntry 锁的时候修改，保证和槽里是否有路由条目一致, 查找时原子读取不用加锁
+	rts      [maskMaxLen]rtEntry[T]
+
+	//默认路由0.0.0.0/0 单独存放，查找时没有更长的掩码匹配就直接返回, 由RWMutex 保护(RWMutex 只用于默认路由),
+	//hasDefault 可以原子读取，没有默认路由时查找不用加锁
+	hasDefault atomic.Bool
+	defRoute   T
+
+	hooksMu sync.Mutex
+	hooks   atomic.Pointer[routeHooks[T]]
+
+	cacheOnce sync.Once
+	cache     atomic.Pointer[lookupCache[T]] //nil if the lookup cache is not enabled
+
+	ttl expiry
+
+	probes atomic.Pointer[probeStats]  //nil if EnableProbeStats is not called
+	vars   atomic.Pointer[expvarStats] //nil if PublishExpvar is not called
+
+	freeEmpty bool //RouteTableOpts.FreeEmptyMaps, not changed after created
+
+	limit limiter
+
+	policy policyRoutes[T]
 }
 
-type rtEntry struct {
+type rtEntry[T any] struct {
 	sync.RWMutex
-	mask   uint32
-	rtHash map[NetWork]interface{}
+	rtKeys []NetWork            //rtArray, sorted, used while rtHash is nil
+	rtVals []T                  //the values of rtKeys
+	rtHash map[NetWork]T        //nil while the routes fit in rtArray
+	peak   int                  //the max len of rtHash, used by Compact
+	holes  map[NetWork]struct{} //the exceptions added by AddException, nil if there is none
+}
+
+// netKey return the key of ip in slot, the network address of ip masked by the prefix mask.
+// the mask is computed from slot instead of stored in rtEntry, so the zero value RouteTable is ready to use
+func netKey(slot int, ip NetWork) NetWork {
+	return ip & NetWork(MaskForSlot(slot))
+}
+
+// routeTable keep the interface{} api, it's just RouteTable[interface{}]
+type routeTable struct {
+	RouteTable[interface{}]
 }
 
 func NewRouteTable() *routeTable {
-	rt := new(routeTable)
-	for i := 0; i < maskMaxLen; i++ {
-		rt.rts[i].mask = (1 << (maskMaxLen - uint32(i))) - 1
-		rt.rts[i].rtHash = make(map[NetWork]interface{})
+	return new(routeTable)
+}
+
+// NewRouteTableOf return an empty table, the same as the zero value RouteTable[T]
+func NewRouteTableOf[T any]() *RouteTable[T] {
+	return new(RouteTable[T])
+}
+
+func parseNetwork(network string) (int, NetWork, error) {
+	_, ipnet, err := net.ParseCIDR(network)
+	if err != nil {
+		return 0, 0, err
+	}
+	return netSlot(ipnet)
+}
+
+// netSlot never read ipnet.IP directly, To4 return nil for the short or empty ip,
+// so a malformed ipnet is reported as an error instead of panic
+func netSlot(ipnet *net.IPNet) (int, NetWork, error) {
+	if ipnet == nil {
+		return 0, 0, fmt.Errorf("%w network: nil", ErrNotIPv4)
+	}
+	ip4 := ipnet.IP.To4()
+	if ip4 == nil {
+		return 0, 0, fmt.Errorf("%w network: %v", ErrNotIPv4, ipnet)
+	}
+
+	maskLen, bits := ipnet.Mask.Size()
+	if bits != 8*net.IPv4len && bits != 8*net.IPv6len {
+		//non-canonical mask like 255.0.255.0, or the mask of a wrong length
+		return 0, 0, fmt.Errorf("%w network: %v", ErrInvalidMask, ipnet)
+	}
+	if bits == 8*net.IPv6len {
+		maskLen -= 8 * (net.IPv6len - net.IPv4len)
+	}
+	if maskLen < 0 {
+		return 0, 0, fmt.Errorf("%w network: %v", ErrInvalidMask, ipnet)
+	}
+	slot := maskMaxLen - maskLen
+	//ParseCIDR already masked the ip, but AddRouteNet may be called with host bits set,
+	//mask it explicitly so the key is always what RouteLookup compute by netKey
+	return slot, NetWork(binary.BigEndian.Uint32(ip4)) & NetWork(MaskForSlot(slot)), nil
+}
+
+// NormalizeCIDR return the canonical form of the ipv4 network, the host bits are masked away,
+// e.g. "10.0.0.1/8" => "10.0.0.0/8", so callers can canonicalize the networks before insert
+func NormalizeCIDR(s string) (string, error) {
+	slot, net, err := parseNetwork(s)
+	if err != nil {
+		return "", err
 	}
-	return rt
+	return slotIPNet(slot, net).String(), nil
 }
 
-func (rt *routeTable) AddRoute(network string, v interface{}) error {
+// AddRoute add or replace the route of network, the host bits of network are masked away,
+// so "10.0.0.0/8" and "10.0.0.1/8" are the same route, use AddRouteStrict to reject the latter
+func (rt *RouteTable[T]) AddRoute(network string, v T) error {
 	_, ipnet, err := net.ParseCIDR(network)
 	if err != nil {
 		return err
 	}
+	return rt.AddRouteNet(ipnet, v)
+}
 
-	maskLen, _ := ipnet.Mask.Size()
-	slot := maskMaxLen - maskLen
-	net := NetWork(binary.BigEndian.Uint32(ipnet.IP))
-	rt.rts[slot].Lock()
-	n := len(rt.rts[slot].rtHash)
-	rt.rts[slot].rtHash[net] = v
-	rt.rts[slot].Unlock()
-
-	//if there are route entry before add, don't need to set slotMask
-	if n > 0 {
-		return nil
+// AddRouteStrict is the same as AddRoute, but reject the network whose host bits are set,
+// e.g. "10.0.0.5/8", which is usually a host address passed as a network by mistake
+func (rt *RouteTable[T]) AddRouteStrict(network string, v T) error {
+	ip, ipnet, err := net.ParseCIDR(network)
+	if err != nil {
+		return err
 	}
+	if !ip.Equal(ipnet.IP) {
+		return fmt.Errorf("host bits set in network %s, should be %v", network, ipnet)
+	}
+	return rt.AddRouteNet(ipnet, v)
+}
 
-	//how to set slotMask atomic ??
-	rt.Lock()
-	if len(rt.rts[slot].rtHash) > 0 {
-		rt.slotMask |= 1 << uint32(slot) //set bit
+// AddRouteNet add the route without parsing the network string,
+// it return ErrInvalidMask if the mask is not contiguous, e.g. 255.0.255.0
+func (rt *RouteTable[T]) AddRouteNet(ipnet *net.IPNet, v T) error {
+	slot, net, err := netSlot(ipnet)
+	if err != nil {
+		return err
 	}
-	rt.Unlock()
-	return nil
+	_, _, err = rt.addRouteLimited(slot, net, v, addAlways)
+	return err
 }
 
-func (rt *routeTable) DelRoute(network string) error {
-	_, ipnet, err := net.ParseCIDR(network)
+// AddRouteBits add the route of ip/maskLen without string parsing, the host bits of ip are masked.
+// it return ErrInvalidMask if maskLen is not in [0, 32]
+func (rt *RouteTable[T]) AddRouteBits(ip uint32, maskLen int, v T) error {
+	slot, net, err := bitsSlot(ip, maskLen)
 	if err != nil {
 		return err
 	}
+	_, _, err = rt.addRouteLimited(slot, net, v, addAlways)
+	return err
+}
 
-	maskLen, _ := ipnet.Mask.Size()
-	slot := maskMaxLen - maskLen
-	net := NetWork(binary.BigEndian.Uint32(ipnet.IP))
-	rt.rts[slot].Lock()
-	delete(rt.rts[slot].rtHash, net)
-	rt.rts[slot].Unlock()
+// DelRouteBits delete the route of ip/maskLen without string parsing, the host bits of ip are masked
+func (rt *RouteTable[T]) DelRouteBits(ip uint32, maskLen int) error {
+	slot, net, err := bitsSlot(ip, maskLen)
+	if err != nil {
+		return err
+	}
+	rt.delRoute(slot, net)
+	return nil
+}
+
+func bitsSlot(ip uint32, maskLen int) (int, NetWork, error) {
+	slot, err := SlotForMask(maskLen)
+	if err != nil {
+		return 0, 0, err
+	}
+	return slot, NetWork(ip & MaskForSlot(slot)), nil
+}
+
+// RouteEntryOf is a route of AddRoutes, ReplaceMaskLevel and ExportByMask,
+// Exception mark an exception added by AddException, its Value is the zero value
+type RouteEntryOf[T any] struct {
+	Network   string `json:"network"`
+	Value     T      `json:"value"`
+	Exception bool   `json:"exception,omitempty"`
+}
 
-	//how to set slotMask atomic ??
-	rt.Lock()
-	if len(rt.rts[slot].rtHash) == 0 {
-		rt.slotMask &= ^(1 << uint32(slot)) //clear bit
+type RouteEntry = RouteEntryOf[interface{}]
+
+// AddRoutes add routes in batch, each slot is locked only once.
+// nothing is added if any network of entries is invalid.
+// on a limited table the routes are added one by one through the limiter, if one is rejected
+// with ErrTableFull, the routes added before it are kept
+func (rt *RouteTable[T]) AddRoutes(entries []RouteEntryOf[T]) error {
+	var slots [maskMaxLen][]rtItem[T]
+	var defaults []T
+	for i, e := range entries {
+		slot, net, err := parseNetwork(e.Network)
+		if err != nil {
+			return fmt.Errorf("entries[%d]: %w", i, err)
+		}
+		if e.Exception {
+			if slot == defaultSlot {
+				return fmt.Errorf("entries[%d]: %w: can't add exception for the default route", i, ErrInvalidMask)
+			}
+			var zero T
+			e.Value = zero
+		}
+		if slot == defaultSlot {
+			defaults = append(defaults, e.Value)
+			continue
+		}
+		slots[slot] = append(slots[slot], rtItem[T]{slot: slot, net: net, v: e.Value, hole: e.Exception})
+	}
+	if rt.limit.max.Load() != 0 {
+		return rt.addItemsLimited(slots[:], defaults)
+	}
+
+	hooks := rt.hooks.Load()
+	var events []RouteEventOf[T]
+	for slot, items := range slots {
+		if len(items) == 0 {
+			continue
+		}
+		rte := &rt.rts[slot]
+		rte.Lock()
+		n := rte.count()
+		for _, item := range items {
+			typ := RouteAdd
+			if rte.set(item.net, item.v) {
+				typ = RouteReplace
+			}
+			if item.hole {
+				rte.addHole(item.net)
+			} else {
+				delete(rte.holes, item.net)
+			}
+			rt.ttl.forget(slotKey{slot, item.net})
+			if hooks != nil {
+				events = append(events, RouteEventOf[T]{Type: typ, Network: slotIPNet(slot, item.net), Value: item.v, Exception: item.hole})
+			}
+		}
+		rte.grown()
+		if n == 0 {
+			rt.setSlotBit(slot)
+		}
+		rte.Unlock()
+	}
+	for _, e := range events {
+		hooks.call(e)
+	}
+	for _, v := range defaults {
+		rt.addRoute(defaultSlot, 0, v, addAlways)
+	}
+	return nil
+}
+
+func (rt *RouteTable[T]) addItemsLimited(slots [][]rtItem[T], defaults []T) error {
+	for _, items := range slots {
+		for _, item := range items {
+			var err error
+			if item.hole {
+				err = rt.addException(item.slot, item.net)
+			} else {
+				_, _, err = rt.addRouteLimited(item.slot, item.net, item.v, addAlways)
+			}
+			if err != nil {
+				return fmt.Errorf("%v: %w", slotIPNet(item.slot, item.net), err)
+			}
+		}
+	}
+	for _, v := range defaults {
+		if _, _, err := rt.addRouteLimited(defaultSlot, 0, v, addAlways); err != nil {
+			return fmt.Errorf("%v: %w", slotIPNet(defaultSlot, 0), err)
+		}
+	}
+	return nil
+}
+
+// ReplaceRoute add the route like AddRoute, and return the old value if the network existed before
+func (rt *RouteTable[T]) ReplaceRoute(network string, v T) (old T, existed bool, err error) {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		return old, false, err
+	}
+	return rt.addRouteLimited(slot, net, v, addAlways)
+}
+
+type addMode int
+
+const (
+	addAlways   addMode = iota
+	addIfExist          //only update the route that existed
+	addIfAbsent         //only add the route that didn't exist
+)
+
+func (m addMode) store(existed bool) bool {
+	return m == addAlways || (m == addIfExist && existed) || (m == addIfAbsent && !existed)
+}
+
+// AddRouteIfAbsent add the route only if the network doesn't exist, the check and the add are atomic.
+// if the network existed, nothing is modified and the existing value is returned.
+// an exception added by AddException counts as existed
+func (rt *RouteTable[T]) AddRouteIfAbsent(network string, v T) (installed bool, existing T, err error) {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		return false, existing, err
+	}
+	existing, existed, err := rt.addRouteLimited(slot, net, v, addIfAbsent)
+	return !existed && err == nil, existing, err
+}
+
+// UpdateRoute update the value of the network, return ErrRouteNotFound if the network doesn't exist
+func (rt *RouteTable[T]) UpdateRoute(network string, v T) error {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		return err
+	}
+	//an exception is not found like GetRoute, it's replaced by AddRoute only
+	updated := false
+	rt.modify(slot, net, func(old T, existed bool) (T, routeOp) {
+		if !existed || (slot != defaultSlot && rt.rts[slot].hole(net)) {
+			return old, opNone
+		}
+		updated = true
+		return v, opStore
+	})
+	if !updated {
+		return ErrRouteNotFound
 	}
-	rt.Unlock()
 	return nil
 }
 
+func (rt *RouteTable[T]) addRoute(slot int, net NetWork, v T, mode addMode) (T, bool) {
+	return rt.modify(slot, net, addFn(v, mode))
+}
+
+// addFn return the fn of modify that store v according to mode
+func addFn[T any](v T, mode addMode) func(old T, existed bool) (T, routeOp) {
+	return func(old T, existed bool) (T, routeOp) {
+		if mode.store(existed) {
+			return v, opStore
+		}
+		return old, opNone
+	}
+}
+
+type routeOp int
+
+const (
+	opNone routeOp = iota
+	opStore
+	opDelete
+	opHole //store the exception, the value is the zero value
+)
+
+// modify store or delete the route of the network according to what fn return, and return the old route.
+// fn is called with the slot locked, so it must not call back into rt.
+// the change is notified to the OnChange hooks after unlocked
+func (rt *RouteTable[T]) modify(slot int, net NetWork, fn func(old T, existed bool) (v T, op routeOp)) (T, bool) {
+	return rt.modifyTTL(slot, net, time.Time{}, fn)
+}
+
+// modifyTTL is modify that set the expiry deadline of the route to at if it's stored,
+// the zero at drop the deadline of the stored or deleted route
+func (rt *RouteTable[T]) modifyTTL(slot int, net NetWork, at time.Time, fn func(old T, existed bool) (v T, op routeOp)) (T, bool) {
+	old, existed, evt := rt.modifyEvent(slot, net, at, fn)
+	rt.emit(evt)
+	return old, existed
+}
+
+// modifyEvent is modifyTTL that return the change instead of notifying it,
+// for the callers that hold another lock the hooks may need, they emit it after unlocked
+func (rt *RouteTable[T]) modifyEvent(slot int, net NetWork, at time.Time, fn func(old T, existed bool) (v T, op routeOp)) (old T, existed bool, evt pendingEvent[T]) {
+	var v T
+	var op routeOp
+	var wasHole bool
+	if slot == defaultSlot {
+		rt.Lock()
+		old, existed = rt.defRoute, rt.hasDefault.Load()
+		v, op = fn(old, existed)
+		switch op {
+		case opStore:
+			rt.defRoute = v
+			rt.hasDefault.Store(true)
+		case opDelete:
+			var zero T
+			rt.defRoute = zero
+			rt.hasDefault.Store(false)
+		}
+		if op != opNone {
+			rt.ttl.set(slotKey{slot, net}, at)
+		}
+		rt.Unlock()
+	} else {
+		rte := &rt.rts[slot]
+		rte.Lock()
+		old, existed = rte.get(net)
+		wasHole = rte.hole(net)
+		v, op = fn(old, existed)
+		switch op {
+		case opStore, opHole:
+			rte.set(net, v)
+			if op == opHole {
+				rte.addHole(net)
+			} else {
+				delete(rte.holes, net)
+			}
+			rte.grown()
+			//if there are route entry before add, don't need to set slotMask
+			if rte.count() == 1 && !existed {
+				rt.setSlotBit(slot)
+			}
+		case opDelete:
+			if existed {
+				rte.del(net)
+				delete(rte.holes, net)
+				if rte.count() == 0 {
+					rt.clearSlotBit(slot)
+					rt.freeHash(rte)
+				}
+			}
+		}
+		if op != opNone {
+			rt.ttl.set(slotKey{slot, net}, at)
+		}
+		rte.Unlock()
+	}
+
+	switch {
+	case (op == opStore || op == opHole) && existed:
+		evt = pendingEvent[T]{typ: RouteReplace, slot: slot, net: net, v: v, hole: op == opHole, ok: true}
+	case op == opStore || op == opHole:
+		evt = pendingEvent[T]{typ: RouteAdd, slot: slot, net: net, v: v, hole: op == opHole, ok: true}
+	case op == opDelete && existed:
+		evt = pendingEvent[T]{typ: RouteDel, slot: slot, net: net, v: old, hole: wasHole, ok: true}
+	}
+	return old, existed, evt
+}
+
+// setSlotBit and clearSlotBit must be called with rt.rts[slot] locked,
+// so that the slotMask bit is always consistent with the routes of the slot.
+// the bit is set or cleared atomically, no table level lock is needed
+func (rt *RouteTable[T]) setSlotBit(slot int) {
+	rt.slotMask.Or(1 << uint32(slot)) //set bit
+}
+
+func (rt *RouteTable[T]) clearSlotBit(slot int) {
+	rt.slotMask.And(^(1 << uint32(slot))) //clear bit
+}
+
+// GetRoute return the value of the network exactly, without longest prefix matching
+func (rt *RouteTable[T]) GetRoute(network string) (T, bool, error) {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		var zero T
+		return zero, false, err
+	}
+
+	v, ok := rt.getRoute(slot, net)
+	return v, ok, nil
+}
+
+func (rt *RouteTable[T]) getRoute(slot int, net NetWork) (T, bool) {
+	if slot == defaultSlot {
+		return rt.getDefault()
+	}
+
+	rt.rts[slot].RLock()
+	v, ok := rt.rts[slot].get(net)
+	hole := rt.rts[slot].hole(net)
+	rt.rts[slot].RUnlock()
+	return v, ok && !hole
+}
+
+func (rt *RouteTable[T]) DelRoute(network string) error {
+	_, err := rt.DelRouteReport(network)
+	return err
+}
+
+// DelRouteReport delete the route and report whether the route existed and was removed
+func (rt *RouteTable[T]) DelRouteReport(network string) (removed bool, err error) {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		return false, err
+	}
+	return rt.delRoute(slot, net), nil
+}
+
+func (rt *RouteTable[T]) delRoute(slot int, net NetWork) bool {
+	existed, evt := rt.delRouteEvent(slot, net)
+	rt.emit(evt)
+	return existed
+}
+
+// delRouteEvent is delRoute that return the change instead of notifying it
+func (rt *RouteTable[T]) delRouteEvent(slot int, net NetWork) (bool, pendingEvent[T]) {
+	_, existed, evt := rt.modifyEvent(slot, net, time.Time{}, func(old T, existed bool) (T, routeOp) {
+		return old, opDelete
+	})
+	return existed, evt
+}
+
+// Clear drop all routes and the policy routes, it lock slot by slot, so it's safe to call with lookup
+func (rt *RouteTable[T]) Clear() {
+	hooks := rt.hooks.Load()
+	for i := range rt.rts {
+		var old []rtItem[T]
+		rte := &rt.rts[i]
+		rte.Lock()
+		if rte.count() > 0 {
+			if hooks != nil {
+				for net, v := range rte.all() {
+					old = append(old, rtItem[T]{slot: i, net: net, v: v, hole: rte.hole(net)})
+				}
+			}
+			rte.rtHash, rte.rtKeys, rte.rtVals = nil, nil, nil
+			rte.holes = nil
+			rte.peak = 0
+			rt.clearSlotBit(i)
+			rt.ttl.forgetSlot(i)
+		}
+		rte.Unlock()
+
+		for _, item := range old {
+			hooks.call(RouteEventOf[T]{Type: RouteDel, Network: slotIPNet(item.slot, item.net), Value: item.v, Exception: item.hole})
+		}
+	}
+	rt.delRoute(defaultSlot, 0)
+	rt.clearPolicy()
+}
+
+// Clone deep copy the table slot by slot, the returned table is independent of rt
+func (rt *RouteTable[T]) Clone() *RouteTable[T] {
+	c := NewRouteTableOf[T]()
+	rt.copyTo(c)
+	return c
+}
+
+func (rt *routeTable) Clone() *routeTable {
+	c := NewRouteTable()
+	rt.copyTo(&c.RouteTable)
+	return c
+}
+
+// copyTo copy all routes to the new table c which is not shared yet
+func (rt *RouteTable[T]) copyTo(c *RouteTable[T]) {
+	c.freeEmpty = rt.freeEmpty
+	for i := range rt.rts {
+		rt.rts[i].RLock()
+		for net, v := range rt.rts[i].all() {
+			c.rts[i].set(net, v)
+		}
+		for net := range rt.rts[i].holes {
+			c.rts[i].addHole(net)
+		}
+		rt.rts[i].RUnlock()
+		if c.rts[i].count() > 0 {
+			c.setSlotBit(i)
+		}
+	}
+
+	if def, ok := rt.getDefault(); ok {
+		c.addRoute(defaultSlot, 0, def, addAlways)
+	}
+	rt.copyLimit(c)
+}
+
+func (rt *RouteTable[T]) Count() int {
+	n := 0
+	for i := range rt.rts {
+		rt.rts[i].RLock()
+		n += rt.rts[i].count()
+		rt.rts[i].RUnlock()
+	}
+	if rt.hasDefault.Load() {
+		n++
+	}
+	return n
+}
+
+func (rt *RouteTable[T]) CountByMask(maskLen int) int {
+	slot, err := SlotForMask(maskLen)
+	if err != nil {
+		return 0
+	}
+	if slot == defaultSlot {
+		if rt.hasDefault.Load() {
+			return 1
+		}
+		return 0
+	}
+
+	rt.rts[slot].RLock()
+	n := rt.rts[slot].count()
+	rt.rts[slot].RUnlock()
+	return n
+}
+
+type rtItem[T any] struct {
+	slot int
+	net  NetWork
+	v    T
+	hole bool //an exception added by AddException, v is the zero value
+}
+
+func (rt *RouteTable[T]) snapshot(slot int) []rtItem[T] {
+	if slot == defaultSlot {
+		if def, ok := rt.getDefault(); ok {
+			return []rtItem[T]{{slot: slot, v: def}}
+		}
+		return nil
+	}
+
+	rt.rts[slot].RLock()
+	items := make([]rtItem[T], 0, rt.rts[slot].count())
+	for net, v := range rt.rts[slot].all() {
+		items = append(items, rtItem[T]{slot: slot, net: net, v: v, hole: rt.rts[slot].hole(net)})
+	}
+	rt.rts[slot].RUnlock()
+	return items
+}
+
+// dropHoles remove the exceptions from items in place, the walkers skip them like RangeScan
+func dropHoles[T any](items []rtItem[T]) []rtItem[T] {
+	n := 0
+	for _, item := range items {
+		if !item.hole {
+			items[n] = item
+			n++
+		}
+	}
+	return items[:n]
+}
+
+// Walk iterate all routes from the longest mask to the shortest, stop if fn return false.
+// each slot is copied under its lock, so fn is called without holding any lock. the exceptions are skipped
+func (rt *RouteTable[T]) Walk(fn func(network *net.IPNet, v T) bool) {
+	for i := 0; i <= defaultSlot; i++ {
+		for _, item := range dropHoles(rt.snapshot(i)) {
+			if !fn(slotIPNet(item.slot, item.net), item.v) {
+				return
+			}
+		}
+	}
+}
+
+// FindByValue return all networks whose value satisfy match, it walks the whole table
+func (rt *RouteTable[T]) FindByValue(match func(v T) bool) []*net.IPNet {
+	var networks []*net.IPNet
+	rt.Walk(func(network *net.IPNet, v T) bool {
+		if match(v) {
+			networks = append(networks, network)
+		}
+		return true
+	})
+	return networks
+}
+
+// RouteLookup return nil if there is no route matched, for the compatibility
 func (rt *routeTable) RouteLookup(ip NetWork) interface{} {
-	rt.RLock()
-	rtMask := rt.slotMask
-	rt.RUnlock()
-	for i := 0; i < maskMaxLen; i++ {
-		//maybe:don't have to iter maskMaxLen times
-		if rtMask == 0 {
-			break
+	v, _ := rt.RouteLookupOK(ip)
+	return v
+}
+
+func (rt *RouteTable[T]) RouteLookup(ip NetWork) (T, bool) {
+	return rt.RouteLookupOK(ip)
+}
+
+// RouteLookupOK report whether a route matched, so a route with nil value can be distinguished from no route
+func (rt *RouteTable[T]) RouteLookupOK(ip NetWork) (T, bool) {
+	var v T
+	var ok bool
+	if c := rt.cache.Load(); c != nil {
+		v, ok = rt.cachedLookup(c, ip)
+	} else {
+		_, _, v, ok = rt.lookup(ip)
+	}
+	rt.recordLookup(ok)
+	return v, ok
+}
+
+// LookupHost only match the /32 host route of ip, it skip the slotMask walk,
+// for the callers that know they want a host route
+func (rt *RouteTable[T]) LookupHost(ip NetWork) (T, bool) {
+	rte := &rt.rts[0]
+	rte.RLock()
+	v, ok := rte.get(ip)
+	hole := rte.hole(ip)
+	rte.RUnlock()
+	return v, ok && !hole
+}
+
+// RouteLookupNoDefault is RouteLookupOK that ignore the default route, the slot walk stop before /0.
+// it doesn't use the lookup cache, the cached result may be the default route
+func (rt *RouteTable[T]) RouteLookupNoDefault(ip NetWork) (T, bool) {
+	_, _, v, ok := rt.lookupSlots(ip, 0, defaultSlot-1)
+	return v, ok
+}
+
+// RouteLookupIP accept both 4 byte and 16 byte(ipv4-in-ipv6) form of ipv4 address,
+// return nil if ip is not ipv4 or there is no route matched
+func (rt *routeTable) RouteLookupIP(ip net.IP) interface{} {
+	v, _ := rt.RouteTable.RouteLookupIP(ip)
+	return v
+}
+
+// RouteLookupIP accept both 4 byte and 16 byte(ipv4-in-ipv6) form of ipv4 address,
+// net.ParseIP("1.2.3.4") and net.IPv4(1, 2, 3, 4) return the 16 byte form.
+// ip.To4() is used instead of reading ip directly, so both forms resolve identically
+func (rt *RouteTable[T]) RouteLookupIP(ip net.IP) (T, bool) {
+	n, err := ipToNetWork(ip)
+	if err != nil {
+		var zero T
+		return zero, false
+	}
+	return rt.RouteLookup(n)
+}
+
+func (rt *RouteTable[T]) RouteLookupString(s string) (T, error) {
+	n, err := ipToNetWork(net.ParseIP(s))
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	v, _ := rt.RouteLookup(n)
+	return v, nil
+}
+
+// IPv4ToNetWork convert ip to the lookup key, NetWork is the big-endian(network byte order)
+// value of the ipv4 address, the same as AddRoute stores. it return 0 if ip is not ipv4
+func IPv4ToNetWork(ip net.IP) NetWork {
+	n, _ := ipToNetWork(ip)
+	return n
+}
+
+// NetWorkToIP convert the big-endian NetWork back to the 4 byte ipv4 address
+func NetWorkToIP(n NetWork) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, uint32(n))
+	return ip
+}
+
+func ipToNetWork(ip net.IP) (NetWork, error) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return 0, fmt.Errorf("%w address: %v", ErrNotIPv4, ip)
+	}
+	return NetWork(binary.BigEndian.Uint32(ip4)), nil
+}
+
+// RouteLookupEntry return the matched network as well as the value
+func (rt *RouteTable[T]) RouteLookupEntry(ip NetWork) (network *net.IPNet, v T, ok bool) {
+	slot, net, v, ok := rt.lookup(ip)
+	if !ok {
+		return nil, v, false
+	}
+	return slotIPNet(slot, net), v, true
+}
+
+// RouteLookupMask write the mask of the matched route into *outMask, it doesn't allocate
+// if *outMask has the capacity of 4 bytes. *outMask is not changed if no route matched
+func (rt *RouteTable[T]) RouteLookupMask(ip NetWork, outMask *net.IPMask) (T, bool) {
+	slot, _, v, ok := rt.lookup(ip)
+	if !ok {
+		return v, false
+	}
+	if cap(*outMask) < net.IPv4len {
+		*outMask = make(net.IPMask, net.IPv4len)
+	}
+	*outMask = (*outMask)[:net.IPv4len]
+	binary.BigEndian.PutUint32(*outMask, MaskForSlot(slot))
+	return v, true
+}
+
+// MatchResultOf is filled by RouteLookupInto, so the caller can reuse it without allocation
+type MatchResultOf[T any] struct {
+	Value   T
+	MaskLen int
+}
+
+type MatchResult = MatchResultOf[interface{}]
+
+// RouteLookupInto fill out with the matched route, it doesn't allocate
+func (rt *RouteTable[T]) RouteLookupInto(ip NetWork, out *MatchResultOf[T]) bool {
+	slot, _, v, ok := rt.lookup(ip)
+	if !ok {
+		return false
+	}
+	out.Value, out.MaskLen = v, maskMaxLen-slot
+	return true
+}
+
+// RouteLookupN probe at most maxProbes slots that have routes, the default route counts as one probe.
+// exhausted is true if the budget ran out before all the slots were probed, then ok is false
+// even if a shorter route may match. maxProbes < 0 means no limit, the same as RouteLookupOK
+func (rt *RouteTable[T]) RouteLookupN(ip NetWork, maxProbes int) (v T, ok bool, exhausted bool) {
+	probes := 0
+	rt.probe(ip, func(_ int, _ NetWork, rv T, found, hole bool) bool {
+		if probes == maxProbes {
+			exhausted = true
+			return false
+		}
+		probes++
+		if found && !hole {
+			v, ok = rv, true
 		}
+		return !found
+	})
+	return v, ok, exhausted
+}
 
+// RouteLookupCtx is RouteLookup that check ctx every ctxCheckSlots probed slots during the slot walk,
+// and return ctx.Err() if ctx is done before a route is matched
+func (rt *RouteTable[T]) RouteLookupCtx(ctx context.Context, ip NetWork) (v T, ok bool, err error) {
+	probes := 0
+	rt.probe(ip, func(_ int, _ NetWork, rv T, found, hole bool) bool {
+		if probes%ctxCheckSlots == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+		probes++
+		if found && !hole {
+			v, ok = rv, true
+		}
+		return !found
+	})
+	return v, ok, err
+}
+
+// RouteLookupPrimaryBackup return the longest match as primary, and the next less specific match
+// as backup in the same slot walk, so the backup next hop can be pre-staged for failover
+func (rt *RouteTable[T]) RouteLookupPrimaryBackup(ip NetWork) (primary, backup T, okPrimary, okBackup bool) {
+	rt.match(ip, func(slot int, net NetWork, v T) bool {
+		if !okPrimary {
+			primary, okPrimary = v, true
+			return true
+		}
+		backup, okBackup = v, true
+		return false
+	})
+	return primary, backup, okPrimary, okBackup
+}
+
+// RouteLookupMinMask only match the routes whose mask length >= minMaskLen
+func (rt *RouteTable[T]) RouteLookupMinMask(ip NetWork, minMaskLen int) (T, bool) {
+	var v T
+	if minMaskLen > maskMaxLen {
+		return v, false
+	}
+	if minMaskLen < 0 {
+		minMaskLen = 0
+	}
+	_, _, v, ok := rt.lookupSlots(ip, 0, maskMaxLen-minMaskLen)
+	return v, ok
+}
+
+// Contains report whether any route match ip
+func (rt *RouteTable[T]) Contains(ip NetWork) bool {
+	_, _, _, ok := rt.lookup(ip)
+	return ok
+}
+
+// RouteLookupWithMask return the mask length of the matched route as well as the value
+func (rt *RouteTable[T]) RouteLookupWithMask(ip NetWork) (v T, maskLen int, ok bool) {
+	slot, _, v, ok := rt.lookup(ip)
+	if !ok {
+		return v, 0, false
+	}
+	return v, maskMaxLen - slot, true
+}
+
+type MatchedRouteOf[T any] struct {
+	Network *net.IPNet
+	Value   T
+}
+
+type MatchedRoute = MatchedRouteOf[interface{}]
+
+// RouteLookupAll return all routes that contain ip, the longest prefix first
+func (rt *RouteTable[T]) RouteLookupAll(ip NetWork) []MatchedRouteOf[T] {
+	var routes []MatchedRouteOf[T]
+	rt.match(ip, func(slot int, net NetWork, v T) bool {
+		routes = append(routes, MatchedRouteOf[T]{Network: slotIPNet(slot, net), Value: v})
+		return true
+	})
+	return routes
+}
+
+func slotIPNet(slot int, key NetWork) *net.IPNet {
+	return &net.IPNet{IP: NetWorkToIP(key), Mask: net.CIDRMask(maskMaxLen-slot, maskMaxLen)}
+}
+
+func (rt *RouteTable[T]) lookup(ip NetWork) (int, NetWork, T, bool) {
+	return rt.lookupSlots(ip, 0, defaultSlot)
+}
+
+// lookupSlots do the longest prefix matching only in the slots from minSlot to maxSlot
+func (rt *RouteTable[T]) lookupSlots(ip NetWork, minSlot, maxSlot int) (int, NetWork, T, bool) {
+	rtMask := rt.slotMask.Load() >> uint32(minSlot)
+	if maxSlot < maskMaxLen {
+		rtMask &= 1<<uint32(maxSlot-minSlot+1) - 1
+	}
+	probes := 0
+	for i := minSlot; rtMask != 0; i++ {
 		if rtMask&1 != 0 {
-			net := ip & NetWork(rt.rts[i].mask)
+			probes++
+			net := netKey(i, ip)
 			rt.rts[i].RLock()
-			if v, ok := rt.rts[i].rtHash[net]; ok {
+			if v, ok := rt.rts[i].get(net); ok {
+				hole := rt.rts[i].hole(net)
 				rt.rts[i].RUnlock()
-				return v
+				rt.recordProbes(probes)
+				return i, net, v, !hole //the exception hide the shorter routes
 			}
 			rt.rts[i].RUnlock()
 		}
 
 		rtMask >>= 1
 	}
-	return nil
+	rt.recordProbes(probes)
+
+	var def T
+	ok := false
+	if maxSlot >= defaultSlot {
+		def, ok = rt.getDefault()
+	}
+	return defaultSlot, 0, def, ok
+}
+
+// match call fn for each slot that contain ip, from the longest mask to the shortest,
+// stop if fn return false. an exception stop the match without calling fn
+func (rt *RouteTable[T]) match(ip NetWork, fn func(slot int, net NetWork, v T) bool) {
+	rt.probe(ip, func(slot int, net NetWork, v T, ok, hole bool) bool {
+		if hole {
+			return false
+		}
+		return !ok || fn(slot, net, v)
+	})
+}
+
+// probe call fn for each slot that has routes and the default route, from the longest mask to the shortest,
+// ok report whether the slot has the route of ip, hole whether the route is an exception.
+// stop if fn return false, fn is called without holding any lock
+func (rt *RouteTable[T]) probe(ip NetWork, fn func(slot int, net NetWork, v T, ok, hole bool) bool) {
+	rtMask := rt.slotMask.Load()
+	for i := 0; rtMask != 0; i++ {
+		if rtMask&1 != 0 {
+			net := netKey(i, ip)
+			rt.rts[i].RLock()
+			v, ok := rt.rts[i].get(net)
+			hole := rt.rts[i].hole(net)
+			rt.rts[i].RUnlock()
+			if !fn(i, net, v, ok, hole) {
+				return
+			}
+		}
+		rtMask >>= 1
+	}
+	if def, ok := rt.getDefault(); ok {
+		fn(defaultSlot, 0, def, true, false)
+	}
+}
+
+func (rt *RouteTable[T]) getDefault() (T, bool) {
+	var def T
+	if !rt.hasDefault.Load() {
+		return def, false
+	}
+	rt.RLock()
+	def, ok := rt.defRoute, rt.hasDefault.Load()
+	rt.RUnlock()
+	return def, ok
 }
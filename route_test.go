@@ -0,0 +1,402 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+)
+
+func ipv4(s string) NetWork {
+	return IPv4ToNetWork(net.ParseIP(s))
+}
+
+func TestZeroValueTable(t *testing.T) {
+	var rt RouteTable[int]
+	if err := rt.AddRoute("10.1.0.0/16", 16); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.AddRoute("10.1.2.0/24", 24); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := rt.RouteLookup(ipv4("10.1.2.3")); !ok || v != 24 {
+		t.Fatalf("lookup 10.1.2.3 = %v, %v, want 24", v, ok)
+	}
+	if v, ok := rt.RouteLookup(ipv4("10.1.9.9")); !ok || v != 16 {
+		t.Fatalf("lookup 10.1.9.9 = %v, %v, want 16", v, ok)
+	}
+	if err := rt.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentAddDelSlotMask(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			network := fmt.Sprintf("10.0.%d.0/24", g)
+			for i := 0; i < 2000; i++ {
+				rt.AddRoute(network, i)
+				rt.DelRoute(network)
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := rt.Count(); n != 0 {
+		t.Fatalf("Count = %d after deleting all routes", n)
+	}
+	if err := rt.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockFreeSlotMask(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	rt.AddRoute("10.0.0.0/8", 8)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		networks := []string{"10.1.0.0/16", "10.1.2.0/24", "10.1.2.3/32"}
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			rt.AddRoute(networks[i%3], 16+8*(i%3))
+			rt.DelRoute(networks[(i+1)%3])
+		}
+	}()
+	var readers sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for i := 0; i < 10000; i++ {
+				if v, ok := rt.RouteLookup(ipv4("10.1.2.3")); !ok || v < 8 {
+					t.Errorf("lookup 10.1.2.3 = %v, %v, want a match", v, ok)
+					return
+				}
+			}
+		}()
+	}
+	readers.Wait()
+	close(stop)
+	wg.Wait()
+	if err := rt.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIPv4ToNetWork(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	rt.AddRoute("1.2.3.0/24", 24)
+	if v, ok := rt.RouteLookup(IPv4ToNetWork(net.ParseIP("1.2.3.4"))); !ok || v != 24 {
+		t.Fatalf("lookup 1.2.3.4 = %v, %v, want 24", v, ok)
+	}
+	if n := IPv4ToNetWork(net.IPv4(1, 2, 3, 4)); n != 0x01020304 {
+		t.Fatalf("IPv4ToNetWork(1.2.3.4) = %#x, want big-endian 0x01020304", uint32(n))
+	}
+	if ip := NetWorkToIP(0x01020304); !ip.Equal(net.IPv4(1, 2, 3, 4)) {
+		t.Fatalf("NetWorkToIP(0x01020304) = %v", ip)
+	}
+	if n := IPv4ToNetWork(net.ParseIP("2001:db8::1")); n != 0 {
+		t.Fatalf("IPv4ToNetWork(ipv6) = %#x, want 0", uint32(n))
+	}
+}
+
+func TestAddRouteIPv6(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	if err := rt.AddRoute("2001:db8::/32", 1); !errors.Is(err, ErrNotIPv4) {
+		t.Fatalf("AddRoute(2001:db8::/32) = %v, want ErrNotIPv4", err)
+	}
+	if err := rt.DelRoute("2001:db8::/32"); !errors.Is(err, ErrNotIPv4) {
+		t.Fatalf("DelRoute(2001:db8::/32) = %v, want ErrNotIPv4", err)
+	}
+	if n := rt.Count(); n != 0 {
+		t.Fatalf("Count = %d after the rejected add", n)
+	}
+	if err := rt.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaultRoute(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	if err := rt.AddRoute("0.0.0.0/0", 0); err != nil {
+		t.Fatal(err)
+	}
+	rt.AddRoute("10.0.0.0/8", 8)
+	if v, ok := rt.RouteLookup(ipv4("192.168.1.1")); !ok || v != 0 {
+		t.Fatalf("lookup 192.168.1.1 = %v, %v, want the default route", v, ok)
+	}
+	if v, ok := rt.RouteLookup(ipv4("10.1.1.1")); !ok || v != 8 {
+		t.Fatalf("lookup 10.1.1.1 = %v, %v, want 8", v, ok)
+	}
+	if n := rt.Count(); n != 2 {
+		t.Fatalf("Count = %d, want 2", n)
+	}
+	if n := rt.CountByMask(0); n != 1 {
+		t.Fatalf("CountByMask(0) = %d, want 1", n)
+	}
+	if c := rt.Clone(); c.Count() != 2 {
+		t.Fatalf("Clone has %d routes, want 2", c.Count())
+	}
+	if err := rt.DelRoute("0.0.0.0/0"); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := rt.RouteLookup(ipv4("192.168.1.1")); ok {
+		t.Fatalf("lookup 192.168.1.1 = %v after deleting the default route", v)
+	}
+	if err := rt.AddRoutes([]RouteEntryOf[int]{{Network: "0.0.0.0/0", Value: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := rt.RouteLookup(ipv4("192.168.1.1")); !ok || v != 1 {
+		t.Fatalf("lookup 192.168.1.1 = %v, %v, want the default route added by AddRoutes", v, ok)
+	}
+	if err := rt.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRouteLookupIntoAllocs(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	rt.AddRoute("10.1.2.0/24", 24)
+	rt.AddRoute("0.0.0.0/0", 0)
+	var out MatchResultOf[int]
+	ips := []NetWork{ipv4("10.1.2.3"), ipv4("192.168.1.1")}
+	for _, ip := range ips {
+		if n := testing.AllocsPerRun(100, func() { rt.RouteLookupInto(ip, &out) }); n != 0 {
+			t.Fatalf("RouteLookupInto(%v) allocate %v times per run", NetWorkToIP(ip), n)
+		}
+	}
+	if !rt.RouteLookupInto(ips[0], &out) || out.Value != 24 || out.MaskLen != 24 {
+		t.Fatalf("RouteLookupInto(10.1.2.3) = %+v", out)
+	}
+}
+
+func TestRouteLookupIPMapped(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	rt.AddRoute("1.2.3.0/24", 24)
+	ip16 := net.ParseIP("1.2.3.4")
+	if len(ip16) != net.IPv6len {
+		t.Fatalf("ParseIP return %d bytes, want the 16 byte form", len(ip16))
+	}
+	for _, ip := range []net.IP{ip16, ip16.To4()} {
+		if v, ok := rt.RouteLookupIP(ip); !ok || v != 24 {
+			t.Fatalf("RouteLookupIP(%d byte %v) = %v, want 24", len(ip), ip, v)
+		}
+	}
+}
+
+func TestHostRoute(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	if err := rt.AddRoute("1.2.3.4/32", 32); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := rt.RouteLookup(ipv4("1.2.3.4")); !ok || v != 32 {
+		t.Fatalf("lookup 1.2.3.4 = %v, %v, want 32", v, ok)
+	}
+	for _, s := range []string{"1.2.3.5", "0.0.0.0", "4.3.2.1"} {
+		if v, ok := rt.RouteLookup(ipv4(s)); ok {
+			t.Fatalf("lookup %s = %v, want no route", s, v)
+		}
+	}
+}
+
+func TestPrefixMask(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	for _, s := range []string{"10.0.0.0/8", "10.1.2.0/24", "172.16.0.0/12", "192.168.1.128/25"} {
+		if err := rt.AddRoute(s, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		ip, network string
+	}{
+		{"10.1.2.255", "10.1.2.0/24"},
+		{"10.1.3.0", "10.0.0.0/8"},
+		{"172.31.255.1", "172.16.0.0/12"},
+		{"192.168.1.200", "192.168.1.128/25"},
+		{"192.168.1.127", ""},
+		{"172.32.0.1", ""},
+	}
+	for _, tt := range tests {
+		network, _, ok := rt.RouteLookupEntry(ipv4(tt.ip))
+		got := ""
+		if ok {
+			got = network.String()
+		}
+		if got != tt.network {
+			t.Errorf("RouteLookupEntry(%s) = %q, want %q", tt.ip, got, tt.network)
+		}
+	}
+}
+
+func TestKeyNormalized(t *testing.T) {
+	host := net.IPv4(10, 11, 12, 13).To4()
+	for maskLen := 0; maskLen <= 32; maskLen++ {
+		rt := NewRouteTableOf[int]()
+		ipnet := &net.IPNet{IP: host, Mask: net.CIDRMask(maskLen, 32)} //host bits are not masked
+		if err := rt.AddRouteNet(ipnet, maskLen); err != nil {
+			t.Fatalf("/%d: %v", maskLen, err)
+		}
+		network := (&net.IPNet{IP: host.Mask(ipnet.Mask), Mask: ipnet.Mask}).String()
+		if v, ok, err := rt.GetRoute(network); err != nil || !ok || v != maskLen {
+			t.Fatalf("GetRoute(%s) = %v, %v, %v", network, v, ok, err)
+		}
+		if v, n, ok := rt.RouteLookupWithMask(ipv4("10.11.12.13")); !ok || v != maskLen || n != maskLen {
+			t.Fatalf("/%d: lookup 10.11.12.13 = %v, /%d, %v", maskLen, v, n, ok)
+		}
+		if err := rt.Validate(); err != nil {
+			t.Fatalf("/%d: %v", maskLen, err)
+		}
+	}
+}
+
+func TestShortIP(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	rt.AddRoute("0.0.0.0/0", 0)
+	for _, ip := range []net.IP{nil, {}, {1, 2, 3}} {
+		if v, ok := rt.RouteLookupIP(ip); ok {
+			t.Errorf("RouteLookupIP(%d byte ip) = %v, want no route", len(ip), v)
+		}
+		ipnet := &net.IPNet{IP: ip, Mask: net.CIDRMask(24, 32)}
+		if err := rt.AddRouteNet(ipnet, 1); !errors.Is(err, ErrNotIPv4) {
+			t.Errorf("AddRouteNet(%d byte ip) = %v, want ErrNotIPv4", len(ip), err)
+		}
+	}
+	if err := rt.AddRouteNet(nil, 1); !errors.Is(err, ErrNotIPv4) {
+		t.Errorf("AddRouteNet(nil) = %v, want ErrNotIPv4", err)
+	}
+	if n := rt.Count(); n != 1 {
+		t.Fatalf("Count = %d, want 1", n)
+	}
+}
+
+func TestInvalidMask(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	masks := []net.IPMask{
+		{255, 0, 255, 0}, //not contiguous
+		{255, 255, 255},  //wrong length
+	}
+	for _, mask := range masks {
+		ipnet := &net.IPNet{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: mask}
+		if err := rt.AddRouteNet(ipnet, 1); !errors.Is(err, ErrInvalidMask) {
+			t.Errorf("AddRouteNet(mask %v) = %v, want ErrInvalidMask", mask, err)
+		}
+	}
+	for _, maskLen := range []int{-1, 33} {
+		if err := rt.AddRouteBits(0x0a000000, maskLen, 1); !errors.Is(err, ErrInvalidMask) {
+			t.Errorf("AddRouteBits(/%d) = %v, want ErrInvalidMask", maskLen, err)
+		}
+	}
+	if n := rt.Count(); n != 0 {
+		t.Fatalf("Count = %d after the rejected adds", n)
+	}
+}
+
+func TestRouteLookupMaskAllocs(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	rt.AddRoute("10.1.2.0/24", 24)
+	mask := make(net.IPMask, net.IPv4len)
+	ip := ipv4("10.1.2.3")
+	if n := testing.AllocsPerRun(100, func() { rt.RouteLookupMask(ip, &mask) }); n != 0 {
+		t.Fatalf("RouteLookupMask allocate %v times per run with a 4 byte mask", n)
+	}
+	if ones, _ := mask.Size(); ones != 24 {
+		t.Fatalf("RouteLookupMask fill /%d, want /24", ones)
+	}
+}
+
+func TestRouteLookupN(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	rt.AddRoute("10.1.2.0/24", 24)
+	rt.AddRoute("10.1.0.0/16", 16)
+	rt.AddRoute("0.0.0.0/0", 0)
+	tests := []struct {
+		ip        string
+		maxProbes int
+		v         interface{}
+		ok        bool
+		exhausted bool
+	}{
+		{"10.1.2.3", 1, 24, true, false},
+		{"10.1.3.3", 1, nil, false, true},
+		{"10.1.3.3", 2, 16, true, false},
+		{"10.2.3.3", 2, nil, false, true},
+		{"10.2.3.3", 3, 0, true, false},
+		{"10.2.3.3", -1, 0, true, false}, //no limit
+		{"10.1.2.3", 0, nil, false, true},
+	}
+	for _, tt := range tests {
+		v, ok, exhausted := rt.RouteLookupN(ipv4(tt.ip), tt.maxProbes)
+		if ok != tt.ok || exhausted != tt.exhausted || (ok && interface{}(v) != tt.v) {
+			t.Errorf("RouteLookupN(%s, %d) = %v, %v, %v, want %v, %v, %v",
+				tt.ip, tt.maxProbes, v, ok, exhausted, tt.v, tt.ok, tt.exhausted)
+		}
+	}
+
+	rt.AddException("10.1.2.0/24")
+	if v, ok, exhausted := rt.RouteLookupN(ipv4("10.1.2.3"), -1); ok || exhausted {
+		t.Errorf("RouteLookupN(10.1.2.3) = %v, %v, %v, want the exception to hide the shorter routes", v, ok, exhausted)
+	}
+}
+
+func TestRouteLookupCtx(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	rt.AddRoute("10.1.2.0/24", 24)
+	rt.AddRoute("0.0.0.0/0", 0)
+	if v, ok, err := rt.RouteLookupCtx(context.Background(), ipv4("10.1.2.3")); err != nil || !ok || v != 24 {
+		t.Fatalf("RouteLookupCtx(10.1.2.3) = %v, %v, %v, want 24", v, ok, err)
+	}
+	if v, ok, err := rt.RouteLookupCtx(context.Background(), ipv4("192.168.1.1")); err != nil || !ok || v != 0 {
+		t.Fatalf("RouteLookupCtx(192.168.1.1) = %v, %v, %v, want the default route", v, ok, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if v, ok, err := rt.RouteLookupCtx(ctx, ipv4("10.1.2.3")); !errors.Is(err, context.Canceled) || ok {
+		t.Fatalf("RouteLookupCtx(canceled) = %v, %v, %v, want context.Canceled", v, ok, err)
+	}
+}
+
+// countCtx count the calls of Err
+type countCtx struct {
+	context.Context
+	n int
+}
+
+func (c *countCtx) Err() error {
+	c.n++
+	return c.Context.Err()
+}
+
+// TestRouteLookupCtxCadence check the ctx is checked by the probed slots, not by the layout of the slots
+func TestRouteLookupCtxCadence(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	for _, maskLen := range []int{32, 24, 16, 8} {
+		rt.AddRoute(fmt.Sprintf("128.0.0.0/%d", maskLen), maskLen)
+	}
+	ctx := &countCtx{Context: context.Background()}
+	if _, ok, err := rt.RouteLookupCtx(ctx, ipv4("10.1.2.3")); ok || err != nil {
+		t.Fatalf("RouteLookupCtx(10.1.2.3) = %v, %v, want no match", ok, err)
+	}
+	if ctx.n != 1 {
+		t.Fatalf("ctx checked %d times for 4 probed slots, want 1", ctx.n)
+	}
+	for maskLen := 1; maskLen <= maskMaxLen; maskLen++ {
+		rt.AddRoute(fmt.Sprintf("128.0.0.0/%d", maskLen), maskLen)
+	}
+	ctx.n = 0
+	rt.RouteLookupCtx(ctx, ipv4("10.1.2.3"))
+	if want := maskMaxLen / ctxCheckSlots; ctx.n != want {
+		t.Fatalf("ctx checked %d times for %d probed slots, want %d", ctx.n, maskMaxLen, want)
+	}
+}
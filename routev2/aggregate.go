@@ -0,0 +1,48 @@
+package routev2
+
+import "time"
+
+// Aggregate merge two sibling prefixes with equal values into their supernet,
+// e.g. 10.0.0.0/24 + 10.0.1.0/24 => 10.0.0.0/23, and the merged supernet may be merged again.
+// it return the number of merges performed.
+// the siblings are not merged if the supernet existed with a different value.
+// the supernet is added before the siblings are deleted, so lookups always get the same value,
+// but it's not atomic with other mutations, the result is exact only if the table is not modified meanwhile
+func (rt *routeTable) Aggregate(eq func(a, b interface{}) bool) int {
+	merges := 0
+	for slot := 0; slot < maskMaxLen; slot++ {
+		bit := NetWork(1) << uint32(slot) //the last bit of the network
+		for _, item := range rt.snapshotSlot(slot) {
+			if item.net&bit != 0 {
+				continue //merged from the lower sibling
+			}
+			a, oka := rt.getRoute(slot, item.net)
+			b, okb := rt.getRoute(slot, item.net|bit)
+			if !oka || !okb || !eq(a, b) {
+				continue
+			}
+
+			super := item.net //the lower sibling is also the key of the supernet
+			sv, ok := rt.getRoute(slot+1, super)
+			if ok && !eq(sv, a) {
+				continue
+			}
+			//the supernet and the deletes of the siblings are done with lim.mu locked,
+			//so a limited add never see the table over the limit meanwhile
+			var evts [3]pendingEvent
+			rt.limit.mu.Lock()
+			if !ok {
+				_, _, evts[0] = rt.modifyEvent(slot+1, super, time.Time{}, addFn(a, addAlways))
+				rt.limitAdded(evts[0])
+			}
+			_, evts[1] = rt.delRouteEvent(slot, item.net)
+			_, evts[2] = rt.delRouteEvent(slot, item.net|bit)
+			rt.limit.mu.Unlock()
+			for _, evt := range evts {
+				rt.emit(evt)
+			}
+			merges++
+		}
+	}
+	return merges
+}
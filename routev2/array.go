@@ -0,0 +1,130 @@
+package routev2
+
+import "iter"
+
+/*
+路由条目少的槽用rtEntry 里内嵌的rtArray 存放，rtNum 是rtArray 里的条目数，查找时线性扫描，
+不用哈希计算，也不用额外分配内存。条目数超过arrayMax 时转成rtHash,
+删除到arrayMax/2 时再转回rtArray, 中间留一段距离，避免在临界点反复转换。
+rtHash 不为nil 就用rtHash, 否则用rtArray.
+BenchmarkArrayVsHash 里rtArray/rtHash 每次查找(arrayMax 临时改成16 测的): 2条 3.4ns/4.9ns, 4条 4.5ns/6.6ns,
+8条 7.2ns/9.5ns, 10条 8.3ns/9.0ns, 12条 9.0ns/9.5ns, 16条 11.2ns/7.3ns, 10~12条之间持平。
+rtArray 内嵌在每个rtEntry 里，arrayMax 越大所有槽越占内存，取8 和根目录一样，也在持平点之前留了余量。
+*/
+const arrayMax = 8
+
+// get return the route of net, must be called with the section locked
+func (rte *rtEntry) get(net NetWork) (interface{}, bool) {
+	if rte.rtHash != nil {
+		v, ok := rte.rtHash[net]
+		return v, ok
+	}
+	for i := 0; i < rte.rtNum; i++ {
+		if rte.rtKeys[i] == net {
+			return rte.rtVals[i], true
+		}
+	}
+	return nil, false
+}
+
+// set store the route of net and report whether it existed, must be called with the section locked
+func (rte *rtEntry) set(net NetWork, v interface{}) bool {
+	if rte.rtHash != nil {
+		_, existed := rte.rtHash[net]
+		rte.rtHash[net] = v
+		return existed
+	}
+	for i := 0; i < rte.rtNum; i++ {
+		if rte.rtKeys[i] == net {
+			rte.rtVals[i] = v
+			return true
+		}
+	}
+	if rte.rtNum == arrayMax {
+		m := make(map[NetWork]interface{}, 2*arrayMax)
+		for i := 0; i < rte.rtNum; i++ {
+			m[rte.rtKeys[i]] = rte.rtVals[i]
+		}
+		m[net] = v
+		rte.resetArray()
+		rte.rtHash = m
+		return false
+	}
+	rte.rtKeys[rte.rtNum], rte.rtVals[rte.rtNum] = net, v
+	rte.rtNum++
+	return false
+}
+
+// del delete the route of net and return the deleted value, must be called with the section locked
+func (rte *rtEntry) del(net NetWork) (interface{}, bool) {
+	if rte.rtHash != nil {
+		v, ok := rte.rtHash[net]
+		if !ok {
+			return nil, false
+		}
+		delete(rte.rtHash, net)
+		if len(rte.rtHash) <= arrayMax/2 {
+			rte.fill(rte.rtHash)
+		}
+		return v, true
+	}
+	for i := 0; i < rte.rtNum; i++ {
+		if rte.rtKeys[i] == net {
+			v := rte.rtVals[i]
+			last := rte.rtNum - 1
+			rte.rtKeys[i], rte.rtVals[i] = rte.rtKeys[last], rte.rtVals[last]
+			rte.rtKeys[last], rte.rtVals[last] = 0, nil //don't keep the deleted value alive
+			rte.rtNum--
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// count return the number of routes in the slot, must be called with the section locked
+func (rte *rtEntry) count() int {
+	if rte.rtHash != nil {
+		return len(rte.rtHash)
+	}
+	return rte.rtNum
+}
+
+// all iterate the routes of the slot in no particular order.
+// must be called with the section locked, and the slot must not be modified during the iteration
+func (rte *rtEntry) all() iter.Seq2[NetWork, interface{}] {
+	return func(yield func(NetWork, interface{}) bool) {
+		if rte.rtHash != nil {
+			for net, v := range rte.rtHash {
+				if !yield(net, v) {
+					return
+				}
+			}
+			return
+		}
+		for i := 0; i < rte.rtNum; i++ {
+			if !yield(rte.rtKeys[i], rte.rtVals[i]) {
+				return
+			}
+		}
+	}
+}
+
+// fill replace the routes of the slot by m, m is used as the rtHash if it's too large for rtArray.
+// must be called with the section locked
+func (rte *rtEntry) fill(m map[NetWork]interface{}) {
+	rte.resetArray()
+	if len(m) > arrayMax {
+		rte.rtHash = m
+		return
+	}
+	rte.rtHash = nil
+	for net, v := range m {
+		rte.rtKeys[rte.rtNum], rte.rtVals[rte.rtNum] = net, v
+		rte.rtNum++
+	}
+}
+
+// resetArray drop all routes of rtArray, must be called with the section locked
+func (rte *rtEntry) resetArray() {
+	rte.rtKeys, rte.rtVals, rte.rtNum = [arrayMax]NetWork{}, [arrayMax]interface{}{}, 0
+}
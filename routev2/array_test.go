@@ -0,0 +1,37 @@
+package routev2
+
+import (
+	"fmt"
+	"testing"
+)
+
+// BenchmarkArrayVsHash compare the lookup in rtArray and rtHash of the same n routes, hit and miss in turn.
+// rtArray is inline and has only arrayMax entries, raise arrayMax to bench the array of larger n
+func BenchmarkArrayVsHash(b *testing.B) {
+	for _, n := range []int{2, 4, 8, 16} {
+		var array, hash rtEntry
+		hash.rtHash = make(map[NetWork]interface{})
+		keys := make([]NetWork, 2*n)
+		for i := 0; i < n; i++ {
+			net := NetWork(i+1) << 8
+			if n <= arrayMax {
+				array.set(net, i)
+			}
+			hash.rtHash[net] = i
+			keys[2*i], keys[2*i+1] = net, net+1
+		}
+		b.Run(fmt.Sprintf("array/%d", n), func(b *testing.B) {
+			if n > arrayMax {
+				b.Skipf("rtArray has only %d entries", arrayMax)
+			}
+			for i := 0; i < b.N; i++ {
+				array.get(keys[i%len(keys)])
+			}
+		})
+		b.Run(fmt.Sprintf("hash/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				hash.get(keys[i%len(keys)])
+			}
+		})
+	}
+}
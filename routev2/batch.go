@@ -0,0 +1,53 @@
+package routev2
+
+// RouteLookupBatch resolve ips into out, out[i] is nil if there is no route for ips[i].
+// each section is read locked only once for the whole batch. out must be the same length as ips
+func (rt *routeTable) RouteLookupBatch(ips []NetWork, out []interface{}) {
+	if len(out) != len(ips) {
+		panic("routev2: RouteLookupBatch len(out) != len(ips)")
+	}
+
+	for i := range out {
+		out[i] = nil
+	}
+	found := make([]bool, len(ips))
+	left := len(ips)
+	secMask := rt.secMask.Load()
+	for i := 0; i < IpSection && left > 0; i++ {
+		if secMask&(1<<uint32(i)) == 0 {
+			continue
+		}
+		sec := &rt.rts[i]
+		sec.RLock()
+		bitMask := sec.slotMask.Load()
+		for k, ip := range ips {
+			if found[k] {
+				continue
+			}
+			m := bitMask
+			for j := 0; m != 0; j++ {
+				if m&1 != 0 {
+					rte := &sec.rtSec[j]
+					if v, ok := rte.get(rte.key(ip)); ok {
+						out[k], found[k] = v, true //an exception is the zero value, the same as no route
+						left--
+						break
+					}
+				}
+				m >>= 1
+			}
+		}
+		sec.RUnlock()
+	}
+
+	if left == 0 {
+		return
+	}
+	if _, _, def, ok := rt.lookupDefault(); ok {
+		for k := range out {
+			if !found[k] {
+				out[k] = def
+			}
+		}
+	}
+}
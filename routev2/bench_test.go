@@ -0,0 +1,96 @@
+package routev2
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// randomTable add n random routes of the mask length 8 to 32, and return the table with the host ips of the routes
+func randomTable(n int) (*routeTable, []NetWork) {
+	r := rand.New(rand.NewSource(1))
+	rt := NewRouteTable()
+	ips := make([]NetWork, n)
+	for i := range ips {
+		ip, maskLen := r.Uint32(), 8+r.Intn(25)
+		rt.AddRouteBits(ip, maskLen, i)
+		ips[i] = NetWork(ip)
+	}
+	return rt, ips
+}
+
+func BenchmarkRouteLookup(b *testing.B) {
+	dense := NewRouteTable()
+	for maskLen := 0; maskLen <= 32; maskLen++ {
+		dense.AddRouteBits(0x0a0b0c0d, maskLen, maskLen)
+	}
+	def := NewRouteTable()
+	def.AddRoute("0.0.0.0/0", 0)
+	hit := NewRouteTable()
+	hit.AddRoute("10.11.12.0/24", 24)
+	hit.AddRoute("10.0.0.0/8", 8)
+
+	tests := []struct {
+		name string
+		rt   *routeTable
+		ip   NetWork
+	}{
+		{"empty", NewRouteTable(), ipv4("10.11.12.13")},
+		{"hit", hit, ipv4("10.11.12.13")},
+		{"miss", hit, ipv4("192.168.1.1")},
+		{"default", def, ipv4("10.11.12.13")},
+		{"dense", dense, ipv4("10.11.12.13")},
+		{"dense-default", dense, ipv4("192.168.1.1")},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				tt.rt.RouteLookup(tt.ip)
+			}
+		})
+	}
+}
+
+func BenchmarkRouteLookupRandom(b *testing.B) {
+	for _, n := range []int{1000, 100000} {
+		rt, ips := randomTable(n)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				rt.RouteLookup(ips[i%len(ips)])
+			}
+		})
+	}
+}
+
+func BenchmarkAddDelRoute(b *testing.B) {
+	rt, _ := randomTable(1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ip := uint32(i) << 8
+		rt.AddRouteBits(ip, 24, i)
+		rt.DelRouteBits(ip, 24)
+	}
+}
+
+func BenchmarkRouteLookupParallel(b *testing.B) {
+	rt, ips := randomTable(100000)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			rt.RouteLookup(ips[i%len(ips)])
+		}
+	})
+}
+
+func BenchmarkRouteLookupInto(b *testing.B) {
+	rt := NewRouteTable()
+	rt.AddRoute("10.1.2.0/24", 24)
+	ip := ipv4("10.1.2.3")
+	var out MatchResult
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rt.RouteLookupInto(ip, &out)
+	}
+}
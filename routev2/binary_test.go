@@ -0,0 +1,51 @@
+package routev2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func encodeString(v interface{}) ([]byte, error) {
+	return []byte(v.(string)), nil
+}
+
+func decodeString(b []byte) (interface{}, error) {
+	return string(b), nil
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	rt := NewRouteTable()
+	rt.AddRoute("10.1.2.0/24", "a")
+	rt.AddRoute("0.0.0.0/0", "default")
+	var buf bytes.Buffer
+	if err := rt.WriteBinary(&buf, encodeString); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadBinary(&buf, decodeString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(rt, nil) {
+		t.Fatalf("ReadBinary = %v, want %v", got, rt)
+	}
+}
+
+func TestReadBinaryValueTooLarge(t *testing.T) {
+	var hdr [4 + 4 + 1 + 4]byte
+	binary.BigEndian.PutUint32(hdr[:4], 1)
+	binary.BigEndian.PutUint32(hdr[4:8], 0x0a000000)
+	hdr[8] = 8
+	binary.BigEndian.PutUint32(hdr[9:], 0xffffffff) //4GB value with no data after it
+	_, err := ReadBinary(bytes.NewReader(hdr[:]), decodeString)
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("ReadBinary = %v, want the value length error", err)
+	}
+
+	rt := NewRouteTable()
+	rt.AddRoute("10.0.0.0/8", strings.Repeat("x", MaxBinaryValueLen+1))
+	if err := rt.WriteBinary(new(bytes.Buffer), encodeString); err == nil {
+		t.Fatal("WriteBinary accept a value longer than MaxBinaryValueLen")
+	}
+}
@@ -0,0 +1,101 @@
+package routev2
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+// lookupCache is a LRU of the RouteLookup results, for the traffic that a small set of
+// destinations dominates. a mutation of the table bump gen, entries of the old gen are stale
+type lookupCache struct {
+	mu    sync.Mutex
+	size  int
+	gen   uint64
+	ll    *list.List //front is the most recently used
+	items map[NetWork]*list.Element
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type cacheEntry struct {
+	ip  NetWork
+	v   interface{}
+	ok  bool
+	gen uint64
+}
+
+func newLookupCache(size int) *lookupCache {
+	return &lookupCache{size: size, ll: list.New(), items: make(map[NetWork]*list.Element, size)}
+}
+
+// get return the cached result, and the current gen that the caller should put the result with
+func (c *lookupCache) get(ip NetWork) (v interface{}, ok, hit bool, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, found := c.items[ip]; found {
+		entry := e.Value.(*cacheEntry)
+		if entry.gen == c.gen {
+			c.ll.MoveToFront(e)
+			c.hits.Add(1)
+			return entry.v, entry.ok, true, c.gen
+		}
+	}
+	c.misses.Add(1)
+	return v, false, false, c.gen
+}
+
+// put cache the result looked up at gen, it's dropped if the table has changed since then
+func (c *lookupCache) put(ip NetWork, v interface{}, ok bool, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	if e, found := c.items[ip]; found {
+		*e.Value.(*cacheEntry) = cacheEntry{ip: ip, v: v, ok: ok, gen: gen}
+		c.ll.MoveToFront(e)
+		return
+	}
+	if c.ll.Len() >= c.size {
+		e := c.ll.Back()
+		delete(c.items, e.Value.(*cacheEntry).ip)
+		c.ll.Remove(e)
+	}
+	c.items[ip] = c.ll.PushFront(&cacheEntry{ip: ip, v: v, ok: ok, gen: gen})
+}
+
+func (c *lookupCache) invalidate() {
+	c.mu.Lock()
+	c.gen++
+	c.mu.Unlock()
+}
+
+// EnableLookupCache put a LRU of size entries in front of RouteLookup and RouteLookupOK,
+// any mutation of the table invalidate all the cached results. size <= 0 disable the cache.
+// the hits and misses are reported by Stats
+func (rt *routeTable) EnableLookupCache(size int) {
+	rt.cacheOnce.Do(func() {
+		rt.OnChange(func(evt RouteEvent) {
+			if c := rt.cache.Load(); c != nil {
+				c.invalidate()
+			}
+		})
+	})
+	if size <= 0 {
+		rt.cache.Store(nil)
+		return
+	}
+	rt.cache.Store(newLookupCache(size))
+}
+
+func (rt *routeTable) cachedLookup(c *lookupCache, ip NetWork) (interface{}, bool) {
+	v, ok, hit, gen := c.get(ip)
+	if hit {
+		return v, ok
+	}
+	_, _, v, ok = rt.lookup(ip)
+	c.put(ip, v, ok, gen)
+	return v, ok
+}
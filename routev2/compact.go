@@ -0,0 +1,35 @@
+package routev2
+
+const (
+	compactMinSize = 64
+	compactRatio   = 4
+)
+
+// grown record the max len of rtHash, must be called with the section locked after inserting
+func (rte *rtEntry) grown() {
+	if n := len(rte.rtHash); n > rte.peak {
+		rte.peak = n
+	}
+}
+
+// Compact rebuild the rtHash that has shrunk to less than 1/compactRatio of its max len,
+// since go map never release its buckets after deleting, the small one is switched to rtArray.
+// it copies the maps under the section lock, so it's O(n) and should be called sparingly, e.g. after a bulk delete
+func (rt *routeTable) Compact() {
+	for i := 0; i < IpSection; i++ {
+		sec := &rt.rts[i]
+		sec.Lock()
+		for j := 0; j < SectionSize; j++ {
+			rte := &sec.rtSec[j]
+			if rte.peak >= compactMinSize && len(rte.rtHash) < rte.peak/compactRatio {
+				m := make(map[NetWork]interface{}, len(rte.rtHash))
+				for net, v := range rte.rtHash {
+					m[net] = v
+				}
+				rte.fill(m)
+				rte.peak = len(m)
+			}
+		}
+		sec.Unlock()
+	}
+}
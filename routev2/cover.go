@@ -0,0 +1,45 @@
+package routev2
+
+import "net"
+
+// CoveringRoute return the longest route that contain network, excluding network itself,
+// e.g. 10.0.0.0/8 for 10.1.2.0/24 if both of them are in the table
+func (rt *routeTable) CoveringRoute(network string) (covering *net.IPNet, v interface{}, ok bool) {
+	slot, net, err := parseNetwork(network)
+	if err != nil || slot == defaultSlot {
+		return nil, v, false
+	}
+
+	slot, net, v, ok = rt.lookupSlots(net, slot+1, defaultSlot)
+	if !ok {
+		return nil, v, false
+	}
+	return slotIPNet(slot, net), v, true
+}
+
+// Overlaps report whether network contain, is contained by, or equal any route in the table
+func (rt *routeTable) Overlaps(network string) bool {
+	slot, super, err := parseNetwork(network)
+	if err != nil {
+		return false
+	}
+	if _, _, _, ok := rt.lookupSlots(super, slot, defaultSlot); ok {
+		return true
+	}
+
+	superMask := NetWork(MaskForSlot(slot))
+	for i := 0; i < IpSection && i*SectionSize < slot; i++ {
+		sec := &rt.rts[i]
+		sec.RLock()
+		for j := 0; j < SectionSize && i*SectionSize+j < slot; j++ {
+			for net := range sec.rtSec[j].all() {
+				if net&superMask == super {
+					sec.RUnlock()
+					return true
+				}
+			}
+		}
+		sec.RUnlock()
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package routev2
+
+import (
+	"net"
+	"reflect"
+)
+
+type slotKey struct {
+	slot int
+	net  NetWork
+}
+
+func (rt *routeTable) items() []rtItem {
+	var items []rtItem
+	for i := 0; i < IpSection; i++ {
+		items = append(items, rt.snapshot(i)...)
+	}
+	return append(items, rt.snapshotSlot(defaultSlot)...)
+}
+
+// Diff compare rt with target, and return what to do to turn rt into target:
+// toAdd are the networks only in target, toDel are the networks only in rt,
+// changed are the networks in both but with different values, or an exception in only one of them.
+// eq compare the values, reflect.DeepEqual is used if eq is nil.
+// the networks are ordered from the longest mask to the shortest
+func (rt *routeTable) Diff(target *routeTable, eq func(a, b interface{}) bool) (toAdd, toDel, changed []*net.IPNet) {
+	if eq == nil {
+		eq = reflect.DeepEqual
+	}
+
+	src, dst := rt.items(), target.items()
+	srcMap := make(map[slotKey]rtItem, len(src))
+	for _, item := range src {
+		srcMap[slotKey{item.slot, item.net}] = item
+	}
+	dstMap := make(map[slotKey]struct{}, len(dst))
+	for _, item := range dst {
+		key := slotKey{item.slot, item.net}
+		dstMap[key] = struct{}{}
+		if s, ok := srcMap[key]; !ok {
+			toAdd = append(toAdd, slotIPNet(item.slot, item.net))
+		} else if s.hole != item.hole || !eq(s.v, item.v) {
+			changed = append(changed, slotIPNet(item.slot, item.net))
+		}
+	}
+	for _, item := range src {
+		if _, ok := dstMap[slotKey{item.slot, item.net}]; !ok {
+			toDel = append(toDel, slotIPNet(item.slot, item.net))
+		}
+	}
+	return toAdd, toDel, changed
+}
+
+// Equal report whether rt and other have exactly the same networks with equal values,
+// eq compare the values, reflect.DeepEqual is used if eq is nil. an exception is only equal to an exception
+func (rt *routeTable) Equal(other *routeTable, eq func(a, b interface{}) bool) bool {
+	if eq == nil {
+		eq = reflect.DeepEqual
+	}
+	if rt.Count() != other.Count() {
+		return false
+	}
+	for _, item := range rt.items() {
+		v, ok, hole := other.rawRoute(item.slot, item.net)
+		if !ok || hole != item.hole || !eq(item.v, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// rawRoute is getRoute without hiding the exceptions, which are stored with nil value,
+// hole report whether the route is an exception
+func (rt *routeTable) rawRoute(slot int, net NetWork) (v interface{}, ok, hole bool) {
+	if slot == defaultSlot {
+		_, _, v, ok = rt.lookupDefault()
+		return v, ok, false
+	}
+	sec, rte, _ := rt.slotEntry(slot)
+	sec.RLock()
+	v, ok = rte.get(net)
+	hole = rte.hole(net)
+	sec.RUnlock()
+	return v, ok, hole
+}
@@ -0,0 +1,72 @@
+package routev2
+
+import "net"
+
+type RouteEventType int
+
+const (
+	RouteAdd RouteEventType = iota
+	RouteDel
+	RouteReplace
+)
+
+type RouteEvent struct {
+	Type    RouteEventType
+	Network *net.IPNet
+	Value   interface{} //the new value for RouteAdd and RouteReplace, the removed value for RouteDel
+	//Exception report the route is an exception added by AddException, Value is nil.
+	//a RouteReplace with Exception false may replace an exception with a real route
+	Exception bool
+}
+
+type routeHooks []func(evt RouteEvent)
+
+func (hooks *routeHooks) call(evt RouteEvent) {
+	for _, fn := range *hooks {
+		fn(evt)
+	}
+}
+
+// OnChange register fn to be notified after each successful mutation.
+// fn is called synchronously by the goroutine that modified the table, after all locks are released,
+// so it may call back into the table, but a slow fn slows down the mutations
+func (rt *routeTable) OnChange(fn func(evt RouteEvent)) {
+	rt.hooksMu.Lock()
+	var hooks routeHooks
+	if old := rt.hooks.Load(); old != nil {
+		hooks = append(hooks, *old...)
+	}
+	hooks = append(hooks, fn)
+	rt.hooks.Store(&hooks)
+	rt.hooksMu.Unlock()
+}
+
+func (rt *routeTable) notify(typ RouteEventType, slot int, net NetWork, v interface{}, hole bool) {
+	hooks := rt.hooks.Load()
+	if hooks == nil {
+		return
+	}
+	hooks.call(RouteEvent{Type: typ, Network: slotIPNet(slot, net), Value: v, Exception: hole})
+}
+
+// pendingEvent is a change to notify after all locks are released, ok is false if nothing changed
+type pendingEvent struct {
+	typ  RouteEventType
+	slot int
+	net  NetWork
+	v    interface{}
+	hole bool
+	ok   bool
+}
+
+func (rt *routeTable) emit(evt pendingEvent) {
+	if evt.ok {
+		rt.notify(evt.typ, evt.slot, evt.net, evt.v, evt.hole)
+	}
+}
+
+func (rt *routeTable) emitAll(evts []pendingEvent) {
+	for _, evt := range evts {
+		rt.emit(evt)
+	}
+}
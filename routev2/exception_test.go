@@ -0,0 +1,210 @@
+package routev2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func exceptionTable() *routeTable {
+	rt := NewRouteTable()
+	rt.AddRoute("10.0.0.0/8", "a")
+	rt.AddException("10.1.0.0/16")
+	rt.AddRoute("0.0.0.0/0", "default")
+	return rt
+}
+
+func checkException(t *testing.T, rt *routeTable) {
+	t.Helper()
+	if !rt.IsException("10.1.0.0/16") {
+		t.Fatal("the exception is lost")
+	}
+	if v, ok := rt.RouteLookupOK(ipv4("10.1.2.3")); ok {
+		t.Fatalf("RouteLookup(10.1.2.3) = %v, want it hidden by the exception", v)
+	}
+	if v, ok := rt.RouteLookupOK(ipv4("10.2.0.1")); !ok || v != "a" {
+		t.Fatalf("RouteLookup(10.2.0.1) = %v, %v, want a", v, ok)
+	}
+}
+
+func TestExceptionBinaryRoundTrip(t *testing.T) {
+	rt := exceptionTable()
+	var buf bytes.Buffer
+	if err := rt.WriteBinary(&buf, encodeString); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadBinary(&buf, decodeString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkException(t, got)
+	if !got.Equal(rt, nil) {
+		t.Fatal("ReadBinary is not Equal to the written table")
+	}
+}
+
+func TestExceptionJSONRoundTrip(t *testing.T) {
+	rt := exceptionTable()
+	data, err := json.Marshal(rt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := NewRouteTable()
+	if err := json.Unmarshal(data, got); err != nil {
+		t.Fatal(err)
+	}
+	checkException(t, got)
+	if !got.Equal(rt, nil) {
+		t.Fatalf("Unmarshal(%s) is not Equal to the marshaled table", data)
+	}
+}
+
+func TestExceptionMerge(t *testing.T) {
+	rt := NewRouteTable()
+	rt.AddRoute("10.1.0.0/16", "b")
+	rt.AddRoute("0.0.0.0/0", "default")
+	conflict := func(_ *net.IPNet, a, b interface{}) interface{} { return a }
+	rt.Merge(exceptionTable(), conflict)
+	checkException(t, rt)
+
+	//a route of other replace the exception of rt
+	other := NewRouteTable()
+	other.AddRoute("10.1.0.0/16", "c")
+	rt.Merge(other, conflict)
+	if rt.IsException("10.1.0.0/16") {
+		t.Fatal("Merge keep the exception replaced by a route")
+	}
+	if v, ok := rt.RouteLookupOK(ipv4("10.1.2.3")); !ok || v != "c" {
+		t.Fatalf("RouteLookup(10.1.2.3) = %v, %v, want c", v, ok)
+	}
+}
+
+func TestExceptionEqual(t *testing.T) {
+	a := NewRouteTable()
+	a.AddException("10.1.0.0/16")
+	b := NewRouteTable()
+	b.AddRoute("10.1.0.0/16", nil) //the exception is stored with the zero value too
+	if a.Equal(b, nil) || b.Equal(a, nil) {
+		t.Fatal("an exception is Equal to a nil route")
+	}
+	if _, _, changed := a.Diff(b, nil); len(changed) != 1 {
+		t.Fatalf("Diff changed = %v, want the exception", changed)
+	}
+	c := NewRouteTable()
+	c.AddException("10.1.0.0/16")
+	if !a.Equal(c, nil) {
+		t.Fatal("equal exceptions are not Equal")
+	}
+}
+
+func TestExceptionExportByMask(t *testing.T) {
+	m := exceptionTable().ExportByMask()
+	if es := m[16]; len(es) != 1 || !es[0].Exception || es[0].Network != "10.1.0.0/16" {
+		t.Fatalf("ExportByMask()[16] = %v, want the exception", es)
+	}
+	if es := m[8]; len(es) != 1 || es[0].Exception {
+		t.Fatalf("ExportByMask()[8] = %v, want a route", es)
+	}
+
+	//the exported entries add the exceptions back
+	var entries []RouteEntry
+	for _, es := range m {
+		entries = append(entries, es...)
+	}
+	rt := NewRouteTable()
+	if err := rt.AddRoutes(entries); err != nil {
+		t.Fatal(err)
+	}
+	checkException(t, rt)
+	rt = NewRouteTable()
+	rt.AddRoute("10.0.0.0/8", "a")
+	if err := rt.ReplaceMaskLevel(16, m[16]); err != nil {
+		t.Fatal(err)
+	}
+	checkException(t, rt)
+	if err := rt.AddRoutes([]RouteEntry{{Network: "0.0.0.0/0", Exception: true}}); err == nil {
+		t.Fatal("AddRoutes accept an exception for the default route")
+	}
+}
+
+func TestExceptionDump(t *testing.T) {
+	rt := NewRouteTable()
+	rt.AddException("10.1.0.0/16")
+	rt.AddRoute("10.2.0.0/16", nil)
+	if got, want := rt.String(), "10.1.0.0/16 except\n10.2.0.0/16 <nil>\n"; got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestExceptionWalkers(t *testing.T) {
+	rt := exceptionTable()
+	rt.AddRoute("10.2.0.0/16", nil) //a real route with nil value
+	want := "[10.2.0.0/16 10.0.0.0/8 0.0.0.0/0]"
+	collect := func(walk func(fn func(network *net.IPNet, v interface{}) bool)) string {
+		var nets []string
+		walk(func(network *net.IPNet, _ interface{}) bool {
+			nets = append(nets, network.String())
+			return true
+		})
+		return fmt.Sprint(nets)
+	}
+	if got := collect(rt.Walk); got != want {
+		t.Errorf("Walk = %s, want %s", got, want)
+	}
+	if got := collect(rt.WalkOrdered); got != want {
+		t.Errorf("WalkOrdered = %s, want %s", got, want)
+	}
+	if got := collect(func(fn func(network *net.IPNet, v interface{}) bool) {
+		it := rt.NewIterator()
+		for network, v, ok := it.Next(); ok; network, v, ok = it.Next() {
+			fn(network, v)
+		}
+	}); got != want {
+		t.Errorf("RouteIterator = %s, want %s", got, want)
+	}
+	var streamed []string
+	for e := range rt.Stream(context.Background()) {
+		streamed = append(streamed, e.Network)
+	}
+	if got := fmt.Sprint(streamed); got != want {
+		t.Errorf("Stream = %s, want %s", got, want)
+	}
+	if got := fmt.Sprint(rt.MoreSpecifics("10.0.0.0/8")); got != "[10.2.0.0/16]" {
+		t.Errorf("MoreSpecifics = %s, want [10.2.0.0/16]", got)
+	}
+	if got := fmt.Sprint(rt.FindByValue(func(v interface{}) bool { return v == nil })); got != "[10.2.0.0/16]" {
+		t.Errorf("FindByValue(nil) = %s, want [10.2.0.0/16]", got)
+	}
+}
+
+func TestExceptionUpdateAndEvents(t *testing.T) {
+	rt := NewRouteTable()
+	var evts []RouteEvent
+	rt.OnChange(func(evt RouteEvent) { evts = append(evts, evt) })
+	rt.AddException("10.1.0.0/16")
+	if err := rt.UpdateRoute("10.1.0.0/16", "a"); !errors.Is(err, ErrRouteNotFound) {
+		t.Fatalf("UpdateRoute of an exception = %v, want ErrRouteNotFound", err)
+	}
+	if !rt.IsException("10.1.0.0/16") {
+		t.Fatal("UpdateRoute turn the exception into a route")
+	}
+	rt.AddRoute("10.1.0.0/16", "b")
+	rt.AddException("10.1.0.0/16")
+	rt.DelRoute("10.1.0.0/16")
+	want := []struct {
+		typ       RouteEventType
+		exception bool
+	}{{RouteAdd, true}, {RouteReplace, false}, {RouteReplace, true}, {RouteDel, true}}
+	if len(evts) != len(want) {
+		t.Fatalf("events = %v, want %v", evts, want)
+	}
+	for i, w := range want {
+		if evts[i].Type != w.typ || evts[i].Exception != w.exception {
+			t.Errorf("event %d = %+v, want %+v", i, evts[i], w)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package routev2
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+type refKey struct {
+	net     uint32
+	maskLen int
+}
+
+func refMask(maskLen int) uint32 {
+	if maskLen == 0 {
+		return 0
+	}
+	return ^uint32(0) << uint(32-maskLen)
+}
+
+// refLookup is the brute-force longest prefix match
+func refLookup(ref map[refKey]int, ip uint32) (int, bool) {
+	for maskLen := 32; maskLen >= 0; maskLen-- {
+		if v, ok := ref[refKey{ip & refMask(maskLen), maskLen}]; ok {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// fuzzOp encode an op of FuzzRouteTable: op | ip [4]byte | maskLen
+func fuzzOp(op byte, ip uint32, maskLen byte) []byte {
+	b := []byte{op, 0, 0, 0, 0, maskLen}
+	binary.BigEndian.PutUint32(b[1:5], ip)
+	return b
+}
+
+func fuzzSeed(ops ...[]byte) []byte {
+	var b []byte
+	for _, op := range ops {
+		b = append(b, op...)
+	}
+	return b
+}
+
+// FuzzRouteTable run random adds, deletes and lookups against a brute-force map of the routes
+func FuzzRouteTable(f *testing.F) {
+	f.Add(fuzzSeed(fuzzOp(0, 0, 0), fuzzOp(2, 0x01020304, 0), fuzzOp(1, 0, 0), fuzzOp(2, 0x01020304, 0)))
+	f.Add(fuzzSeed(fuzzOp(0, 0x0a010203, 32), fuzzOp(0, 0x0a000000, 8), fuzzOp(2, 0x0a010203, 0),
+		fuzzOp(1, 0x0a010203, 32), fuzzOp(2, 0x0a010203, 0)))
+	f.Add(fuzzSeed(fuzzOp(0, 0xffffffff, 32), fuzzOp(0, 0, 0), fuzzOp(0, 0x80000000, 1), fuzzOp(2, 0xffffffff, 0)))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		rt := NewRouteTable()
+		ref := make(map[refKey]int)
+		for i := 0; len(data) >= 6; i, data = i+1, data[6:] {
+			ip := binary.BigEndian.Uint32(data[1:5])
+			maskLen := int(data[5]) % 33
+			key := refKey{ip & refMask(maskLen), maskLen}
+			switch data[0] % 3 {
+			case 0:
+				if err := rt.AddRouteBits(ip, maskLen, i); err != nil {
+					t.Fatalf("op %d: AddRouteBits(%#x/%d): %v", i, ip, maskLen, err)
+				}
+				ref[key] = i
+			case 1:
+				if err := rt.DelRouteBits(ip, maskLen); err != nil {
+					t.Fatalf("op %d: DelRouteBits(%#x/%d): %v", i, ip, maskLen, err)
+				}
+				delete(ref, key)
+			}
+			v, ok := rt.RouteLookupOK(NetWork(ip))
+			if rv, rok := refLookup(ref, ip); ok != rok || ok && v != rv {
+				t.Fatalf("op %d: RouteLookup(%#x) = %v, %v, want %v, %v", i, ip, v, ok, rv, rok)
+			}
+			if err := rt.Validate(); err != nil {
+				t.Fatalf("op %d: Validate: %v", i, err)
+			}
+			if n := rt.Count(); n != len(ref) {
+				t.Fatalf("op %d: Count = %d, want %d", i, n, len(ref))
+			}
+		}
+	})
+}
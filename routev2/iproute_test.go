@@ -0,0 +1,44 @@
+package routev2
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadFromIPRouteDefault(t *testing.T) {
+	const out = `default via 192.168.1.1 dev eth0 proto dhcp metric 100
+default via fe80::1 dev eth0 proto ra metric 1024
+10.0.0.0/8 via inet 192.168.1.2 dev eth0
+192.168.1.0/24 dev eth0 proto kernel scope link src 192.168.1.10
+`
+	rt := NewRouteTable()
+	n, err := rt.LoadFromIPRoute(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("LoadFromIPRoute load %d routes, want 3", n)
+	}
+	v, ok, _ := rt.GetRoute("0.0.0.0/0")
+	if r, _ := v.(IPRoute); !ok || r.Via.String() != "192.168.1.1" {
+		t.Fatalf("default route = %v, %v, want via 192.168.1.1", v, ok)
+	}
+	v, _, _ = rt.GetRoute("10.0.0.0/8")
+	if r, _ := v.(IPRoute); r.Via.String() != "192.168.1.2" {
+		t.Fatalf("10.0.0.0/8 = %v, want via 192.168.1.2", v)
+	}
+}
+
+func TestParseIPRouteDefaultIPv6(t *testing.T) {
+	for _, line := range []string{
+		"default via fe80::1 dev eth0 proto ra metric 1024",
+		"default via inet6 fe80::1 dev eth0",
+	} {
+		if network, _, ok := parseIPRoute(line); ok {
+			t.Errorf("parseIPRoute(%q) = %s, want skipped", line, network)
+		}
+	}
+	if network, v, ok := parseIPRoute("default dev ppp0 scope link"); !ok || network != "0.0.0.0/0" || v.Dev != "ppp0" {
+		t.Errorf("parseIPRoute(default dev ppp0) = %s, %+v, %v", network, v, ok)
+	}
+}
@@ -0,0 +1,60 @@
+package routev2
+
+import (
+	"context"
+	"net"
+)
+
+// RouteIterator return the routes one by one from the longest mask to the shortest.
+// the routes of a section are copied when the iterator reaches it, so the table can be
+// modified during the iteration: a section already copied is not affected by the modification
+type RouteIterator struct {
+	rt    *routeTable
+	ipID  int //IpSection means the default route
+	items []rtItem
+}
+
+func (rt *routeTable) NewIterator() *RouteIterator {
+	return &RouteIterator{rt: rt}
+}
+
+// Next return the next route, ok is false if there are no more routes
+func (it *RouteIterator) Next() (network *net.IPNet, v interface{}, ok bool) {
+	for len(it.items) == 0 {
+		switch {
+		case it.ipID < IpSection:
+			it.items = dropHoles(it.rt.snapshot(it.ipID))
+		case it.ipID == IpSection:
+			it.items = it.rt.snapshotSlot(defaultSlot)
+		default:
+			return nil, nil, false
+		}
+		it.ipID++
+	}
+	item := it.items[0]
+	it.items = it.items[1:]
+	return slotIPNet(item.slot, item.net), item.v, true
+}
+
+// Stream send the routes to the returned channel from the longest mask to the shortest,
+// the channel is closed after all routes are sent or ctx is done.
+// each section is copied under its lock and sent without lock, so a slow consumer doesn't block mutations
+func (rt *routeTable) Stream(ctx context.Context) <-chan RouteEntry {
+	ch := make(chan RouteEntry)
+	go func() {
+		defer close(ch)
+		it := rt.NewIterator()
+		for {
+			network, v, ok := it.Next()
+			if !ok {
+				return
+			}
+			select {
+			case ch <- RouteEntry{Network: network.String(), Value: v}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,41 @@
+package routev2
+
+import (
+	"encoding/json"
+	"net"
+)
+
+// MarshalJSON encode the table as a list of {"network": "10.0.0.0/8", "value": ...},
+// the value is encoded by encoding/json directly, an exception is {"network": ..., "value": null, "exception": true}
+func (rt *routeTable) MarshalJSON() ([]byte, error) {
+	routes := []RouteEntry{}
+	for _, item := range rt.items() {
+		routes = append(routes, RouteEntry{Network: slotIPNet(item.slot, item.net).String(), Value: item.v, Exception: item.hole})
+	}
+	return json.Marshal(routes)
+}
+
+// UnmarshalJSON add the routes encoded by MarshalJSON to rt, rt must be created by NewRouteTable.
+// the value is decoded by encoding/json into interface{}, so it's map[string]interface{},
+// float64 ... rather than the original type
+func (rt *routeTable) UnmarshalJSON(data []byte) error {
+	var routes []RouteEntry
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return err
+	}
+	for _, r := range routes {
+		_, ipnet, err := net.ParseCIDR(r.Network)
+		if err != nil {
+			return err
+		}
+		if r.Exception {
+			err = rt.AddException(r.Network)
+		} else {
+			err = rt.AddRouteNet(ipnet, r.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package routev2
+
+import "fmt"
+
+// ReplaceMaskLevel replace all routes of maskLen with entries atomically: lookups see either
+// the old routes or the new ones of the mask length, without the add/del churn.
+// all entries must be of maskLen, nothing is replaced otherwise.
+// on a limited table it return ErrTableFull if the table would exceed the limit after the replace,
+// no route is evicted for it
+func (rt *routeTable) ReplaceMaskLevel(maskLen int, entries []RouteEntry) error {
+	slot, err := SlotForMask(maskLen)
+	if err != nil {
+		return err
+	}
+	var holes map[NetWork]struct{}
+	m := make(map[NetWork]interface{}, len(entries))
+	for i, e := range entries {
+		s, net, err := parseNetwork(e.Network)
+		if err != nil {
+			return fmt.Errorf("entries[%d]: %w", i, err)
+		}
+		if s != slot {
+			return fmt.Errorf("entries[%d]: %w %s, should be /%d", i, ErrInvalidMask, e.Network, maskLen)
+		}
+		if e.Exception {
+			if slot == defaultSlot {
+				return fmt.Errorf("entries[%d]: %w: can't add exception for the default route", i, ErrInvalidMask)
+			}
+			if holes == nil {
+				holes = make(map[NetWork]struct{})
+			}
+			holes[net] = struct{}{}
+			e.Value = nil
+		}
+		m[net] = e.Value
+	}
+
+	if slot == defaultSlot {
+		if len(m) == 0 {
+			rt.delRoute(defaultSlot, 0)
+		}
+		for _, v := range m {
+			if _, _, err := rt.addRouteLimited(defaultSlot, 0, v, addAlways); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	//on a limited table the other slots only grow with lim.mu locked, so they're counted before locking the slot
+	lim := &rt.limit
+	lim.mu.Lock()
+	max, total := int(lim.max.Load()), 0
+	if max > 0 {
+		total = rt.Count()
+	}
+
+	hooks := rt.hooks.Load()
+	var events []RouteEvent
+	var added []NetWork
+	sec, rte, _ := rt.slotEntry(slot)
+	sec.Lock()
+	if max > 0 {
+		if total-rte.count()+len(m) > max {
+			sec.Unlock()
+			lim.mu.Unlock()
+			return ErrTableFull
+		}
+		if lim.policy == EvictOldest {
+			for net := range m {
+				if _, ok := rte.get(net); !ok {
+					added = append(added, net)
+				}
+			}
+		}
+	}
+	if hooks != nil {
+		for net, v := range rte.all() {
+			if _, ok := m[net]; !ok {
+				events = append(events, RouteEvent{Type: RouteDel, Network: slotIPNet(slot, net), Value: v, Exception: rte.hole(net)})
+			}
+		}
+		for net, v := range m {
+			typ := RouteAdd
+			if _, ok := rte.get(net); ok {
+				typ = RouteReplace
+			}
+			_, hole := holes[net]
+			events = append(events, RouteEvent{Type: typ, Network: slotIPNet(slot, net), Value: v, Exception: hole})
+		}
+	}
+	rte.fill(m)
+	rte.holes, rte.peak = holes, len(m)
+	rt.ttl.forgetSlot(slot)
+	if len(m) > 0 {
+		rt.setSlotBit(slot)
+	} else {
+		rt.clearSlotBit(slot)
+		rt.freeHash(rte)
+	}
+	sec.Unlock()
+	for _, net := range added {
+		rt.pushOldest(slotKey{slot, net})
+	}
+	lim.mu.Unlock()
+
+	for _, e := range events {
+		hooks.call(e)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package routev2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLimitHookReentry(t *testing.T) {
+	rt := NewRouteTableWithLimit(2, EvictOldest)
+	reentered := false
+	rt.OnChange(func(evt RouteEvent) {
+		//re-enter the limited add from the hook of an eviction, it deadlock if lim.mu is held
+		if evt.Type == RouteDel && !reentered {
+			reentered = true
+			if err := rt.AddRoute("10.9.0.0/16", "hook"); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			rt.AddRoute(fmt.Sprintf("10.%d.0.0/16", i), i)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the hook deadlock on the limited add")
+	}
+	if !reentered {
+		t.Fatal("no route is evicted")
+	}
+	if n := rt.Count(); n > 2 {
+		t.Fatalf("Count = %d, want <= 2", n)
+	}
+}
+
+func TestLimitEvictNone(t *testing.T) {
+	rt := NewRouteTableWithLimit(1, EvictNone)
+	if err := rt.AddRoute("10.0.0.0/8", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.AddRoute("11.0.0.0/8", 2); !errors.Is(err, ErrTableFull) {
+		t.Fatalf("AddRoute = %v, want ErrTableFull", err)
+	}
+	if err := rt.AddRoute("10.0.0.0/8", 3); err != nil {
+		t.Fatalf("replace on a full table: %v", err)
+	}
+}
+
+func TestLimitAllInsertPaths(t *testing.T) {
+	full := func() *routeTable {
+		rt := NewRouteTableWithLimit(2, EvictNone)
+		rt.AddRoute("10.0.0.0/8", 1)
+		rt.AddRoute("11.0.0.0/8", 2)
+		return rt
+	}
+	other := NewRouteTable()
+	other.AddRoute("12.0.0.0/8", 3)
+	paths := map[string]func(rt *routeTable) error{
+		"AddRoutes": func(rt *routeTable) error {
+			return rt.AddRoutes([]RouteEntry{{Network: "12.0.0.0/8", Value: 3}})
+		},
+		"ReplaceMaskLevel": func(rt *routeTable) error {
+			return rt.ReplaceMaskLevel(16, []RouteEntry{{Network: "12.1.0.0/16", Value: 3}})
+		},
+		"AddRouteTTL": func(rt *routeTable) error {
+			return rt.AddRouteTTL("12.0.0.0/8", 3, time.Hour)
+		},
+		"AddException": func(rt *routeTable) error {
+			return rt.AddException("10.1.0.0/16")
+		},
+		"Merge": func(rt *routeTable) error {
+			return rt.Merge(other, func(_ *net.IPNet, a, b interface{}) interface{} { return a })
+		},
+		"AddRoutePolicy": func(rt *routeTable) error {
+			//the policy routes are counted separately, up to the same limit
+			for _, src := range []string{"1.0.0.0/8", "2.0.0.0/8"} {
+				if err := rt.AddRoutePolicy("12.0.0.0/8", src, 3); err != nil {
+					return err
+				}
+			}
+			if err := rt.AddRoutePolicy("12.0.0.0/8", "1.0.0.0/8", 4); err != nil {
+				return fmt.Errorf("replace a policy route: %w", err)
+			}
+			return rt.AddRoutePolicy("12.0.0.0/8", "3.0.0.0/8", 3)
+		},
+		"UnmarshalJSON": func(rt *routeTable) error {
+			return json.Unmarshal([]byte(`[{"network":"10.1.0.0/16","exception":true}]`), rt)
+		},
+	}
+	for name, add := range paths {
+		rt := full()
+		if err := add(rt); !errors.Is(err, ErrTableFull) {
+			t.Errorf("%s = %v, want ErrTableFull", name, err)
+		}
+		if n := rt.Count(); n != 2 {
+			t.Errorf("%s: Count = %d, want 2", name, n)
+		}
+	}
+
+	//ReplaceMaskLevel of the full slot is fine if the count doesn't grow
+	rt := full()
+	if err := rt.ReplaceMaskLevel(8, []RouteEntry{{Network: "12.0.0.0/8", Value: 3}}); err != nil {
+		t.Fatal(err)
+	}
+
+}
+
+func TestLimitEvictOldestBulk(t *testing.T) {
+	rt := NewRouteTableWithLimit(2, EvictOldest)
+	if err := rt.AddRoutes([]RouteEntry{{Network: "10.0.0.0/8", Value: 1}, {Network: "11.0.0.0/8", Value: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	//the routes of AddRoutes are tracked for EvictOldest, so the add evict one of them
+	if err := rt.AddRoute("12.0.0.0/8", 3); err != nil {
+		t.Fatal(err)
+	}
+	if n := rt.Count(); n != 2 {
+		t.Fatalf("Count = %d, want 2", n)
+	}
+}
+
+func TestSetLimitEvictOldestExisting(t *testing.T) {
+	rt := NewRouteTableWithLimit(0, EvictOldest)
+	rt.AddRoute("10.0.0.0/8", 1)
+	rt.AddRoute("11.0.0.0/8", 2)
+	rt.SetLimit(2)
+	//the routes added before SetLimit are evicted as the oldest
+	if err := rt.AddRoute("12.0.0.0/8", 3); err != nil {
+		t.Fatalf("AddRoute = %v, want the oldest evicted", err)
+	}
+	if n := rt.Count(); n != 2 {
+		t.Fatalf("Count = %d, want 2", n)
+	}
+}
+
+func TestCloneKeepLimit(t *testing.T) {
+	rt := NewRouteTableWithLimit(1, EvictNone)
+	rt.AddRoute("10.0.0.0/8", 1)
+	c := rt.Clone()
+	if c.Limit() != 1 {
+		t.Fatalf("Limit of the clone = %d, want 1", c.Limit())
+	}
+	if err := c.AddRoute("11.0.0.0/8", 2); !errors.Is(err, ErrTableFull) {
+		t.Fatalf("AddRoute to the clone = %v, want ErrTableFull", err)
+	}
+
+	//the clone evict in the same order
+	rt = NewRouteTableWithLimit(2, EvictOldest)
+	rt.AddRoute("10.0.0.0/8", 1)
+	rt.AddRoute("11.0.0.0/8", 2)
+	c = rt.Clone()
+	c.AddRoute("12.0.0.0/8", 3)
+	if _, ok, _ := c.GetRoute("10.0.0.0/8"); ok {
+		t.Fatal("the clone didn't evict the oldest route")
+	}
+	if _, ok, _ := c.GetRoute("11.0.0.0/8"); !ok {
+		t.Fatal("the clone evicted a newer route")
+	}
+}
+
+func TestClearPolicyRoutes(t *testing.T) {
+	rt := NewRouteTable()
+	rt.AddRoute("10.0.0.0/8", 1)
+	rt.AddRoutePolicy("10.0.0.0/8", "1.0.0.0/8", 2)
+	rt.Clear()
+	if v, ok := rt.RouteLookupPolicy(ipv4("10.1.2.3"), ipv4("1.2.3.4")); ok {
+		t.Fatalf("RouteLookupPolicy after Clear = %v, want not found", v)
+	}
+}
@@ -0,0 +1,49 @@
+package routev2
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// Merge add all routes of other to rt, if a network exists in both tables,
+// onConflict pick the value from a(the value in rt) and b(the value in other).
+// an exception of other replace the route of rt, and a route of other replace the exception of rt,
+// like AddException and AddRoute do, onConflict is not called for them.
+// other is copied section by section, so it's safe to modify other meanwhile.
+// onConflict is called with the section of rt locked, it must not call back into rt.
+// if rt is limited, the routes are added through the limiter, Merge stop at the first one
+// rejected with ErrTableFull and return it, the routes merged before are kept
+func (rt *routeTable) Merge(other *routeTable, onConflict func(network *net.IPNet, a, b interface{}) interface{}) error {
+	for i := 0; i < IpSection; i++ {
+		if err := rt.mergeItems(other.snapshot(i), onConflict); err != nil {
+			return err
+		}
+	}
+	return rt.mergeItems(other.snapshotSlot(defaultSlot), onConflict)
+}
+
+func (rt *routeTable) mergeItems(items []rtItem, onConflict func(network *net.IPNet, a, b interface{}) interface{}) error {
+	for _, item := range items {
+		b, slot, net := item.v, item.slot, item.net
+		var err error
+		if item.hole {
+			err = rt.addException(slot, net)
+		} else {
+			var rte *rtEntry //the default slot has no exception
+			if slot != defaultSlot {
+				_, rte, _ = rt.slotEntry(slot)
+			}
+			_, _, err = rt.modifyLimited(slot, net, time.Time{}, func(a interface{}, existed bool) (interface{}, routeOp) {
+				if existed && (rte == nil || !rte.hole(net)) {
+					return onConflict(slotIPNet(slot, net), a, b), opStore
+				}
+				return b, opStore
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("%v: %w", slotIPNet(slot, net), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package routev2
+
+type RouteTableOpts struct {
+	//FreeEmptyMaps free the rtHash and the exceptions of a mask length when its last route is deleted,
+	//and make them again on the next add. the rtArray is inline and has nothing to free, the rtHash
+	//is switched back to rtArray before it's empty unless it's made by Reserve
+	FreeEmptyMaps bool
+}
+
+// freeHash free the rtHash of the emptied slot if FreeEmptyMaps, must be called with the section locked
+func (rt *routeTable) freeHash(rte *rtEntry) {
+	if rt.freeEmpty {
+		rte.rtHash, rte.holes, rte.peak = nil, nil, 0
+	}
+}
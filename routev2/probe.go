@@ -0,0 +1,33 @@
+package routev2
+
+import "sync/atomic"
+
+// probeStats is the histogram of how many slots a lookup probe, index n is the number of
+// the lookups that probed n slots, the default route is not counted as a probe
+type probeStats struct {
+	hist [maskMaxLen + 1]atomic.Uint64
+}
+
+// EnableProbeStats start to record how many slots each RouteLookup probe before matching
+// or giving up, the histogram is returned by ProbeHistogram. it reset the recorded histogram if
+// it's already enabled. it tells whether the slotMask pays off for the prefix distribution
+func (rt *routeTable) EnableProbeStats() {
+	rt.probes.Store(new(probeStats))
+}
+
+// ProbeHistogram return the probe histogram recorded since EnableProbeStats, all 0 if not enabled
+func (rt *routeTable) ProbeHistogram() [maskMaxLen + 1]uint64 {
+	var hist [maskMaxLen + 1]uint64
+	if ps := rt.probes.Load(); ps != nil {
+		for i := range hist {
+			hist[i] = ps.hist[i].Load()
+		}
+	}
+	return hist
+}
+
+func (rt *routeTable) recordProbes(n int) {
+	if ps := rt.probes.Load(); ps != nil {
+		ps.hist[n].Add(1)
+	}
+}
@@ -0,0 +1,31 @@
+package routev2
+
+import "net"
+
+// RangeScan return the routes whose network address is in [start, end] whatever the mask is,
+// from the longest mask to the shortest. it's not the containment, e.g. 10.0.0.0/8 is in
+// [10.0.0.0, 10.0.0.255], but 9.0.0.0/7 is not though it contain the range
+func (rt *routeTable) RangeScan(start, end NetWork) []*net.IPNet {
+	var nets []*net.IPNet
+	secMask := rt.secMask.Load()
+	for i := 0; i < IpSection; i++ {
+		if secMask&(1<<uint32(i)) == 0 {
+			continue
+		}
+		sec := &rt.rts[i]
+		sec.RLock()
+		for j := 0; j < SectionSize; j++ {
+			rte := &sec.rtSec[j]
+			for net := range rte.all() {
+				if net >= start && net <= end && !rte.hole(net) {
+					nets = append(nets, slotIPNet(i*SectionSize+j, net))
+				}
+			}
+		}
+		sec.RUnlock()
+	}
+	if _, _, _, ok := rt.lookupDefault(); ok && start == 0 {
+		nets = append(nets, slotIPNet(defaultSlot, 0))
+	}
+	return nets
+}
@@ -0,0 +1,27 @@
+package routev2
+
+// Reserve pre-allocate the rtHash of maskLen for n routes to avoid rehashing on bulk load,
+// the routes already in the slot are kept. maskLen 0 has only one route, and n <= arrayMax
+// fit in the rtArray, nothing to reserve
+func (rt *routeTable) Reserve(maskLen int, n int) error {
+	slot, err := SlotForMask(maskLen)
+	if err != nil {
+		return err
+	}
+	if slot == defaultSlot || n <= arrayMax {
+		return nil
+	}
+
+	sec, rte, _ := rt.slotEntry(slot)
+	sec.Lock()
+	if rte.count() < n {
+		m := make(map[NetWork]interface{}, n)
+		for net, v := range rte.all() {
+			m[net] = v
+		}
+		rte.resetArray()
+		rte.rtHash = m
+	}
+	sec.Unlock()
+	return nil
+}
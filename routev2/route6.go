@@ -0,0 +1,144 @@
+package routev2
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+/*
+ipv6 路由表，和ipv4 的分段设计一样，只是掩码长度有0~128 共129种，
+每个分段用一个uint64 来表示哪个槽有路由条目，所以需要3个分段。
+*/
+const (
+	maskMaxLen6  = 128
+	SectionSize6 = 64
+	IpSection6   = (maskMaxLen6 + SectionSize6) / SectionSize6
+)
+
+type NetWork6 [net.IPv6len]byte
+
+type route6Table struct {
+	rts [IpSection6]rt6Section
+}
+
+type rt6Section struct {
+	sync.RWMutex
+	slotMask uint64 //SectionSize6 bit
+	rtSec    [SectionSize6]rt6Entry
+}
+
+type rt6Entry struct {
+	mask   NetWork6
+	rtHash map[NetWork6]interface{}
+}
+
+func NewRoute6Table() *route6Table {
+	rt := new(route6Table)
+	idx := 0
+	for i := 0; i < IpSection6; i++ {
+		for j := 0; j < SectionSize6; j++ {
+			idx = i*SectionSize6 + j
+			if idx > maskMaxLen6 {
+				break
+			}
+			section := &rt.rts[i]
+			copy(section.rtSec[j].mask[:], net.CIDRMask(maskMaxLen6-idx, maskMaxLen6))
+			section.rtSec[j].rtHash = make(map[NetWork6]interface{})
+		}
+	}
+	return rt
+}
+
+func (n NetWork6) and(mask NetWork6) NetWork6 {
+	for i := range n {
+		n[i] &= mask[i]
+	}
+	return n
+}
+
+func parseNetwork6(network string) (int, NetWork6, error) {
+	var net6 NetWork6
+	_, ipnet, err := net.ParseCIDR(network)
+	if err != nil {
+		return 0, net6, err
+	}
+	if ipnet.IP.To4() != nil {
+		return 0, net6, fmt.Errorf("%w network: %s", ErrNotIPv6, network)
+	}
+
+	maskLen, _ := ipnet.Mask.Size()
+	slot := maskMaxLen6 - maskLen
+	copy(net6[:], ipnet.IP.To16())
+	return slot, net6, nil
+}
+
+func (rt *route6Table) slotEntry(slot int) (*rt6Section, *rt6Entry, int) {
+	ipID := slot / SectionSize6
+	secID := slot % SectionSize6
+	sec := &rt.rts[ipID]
+	return sec, &sec.rtSec[secID], secID
+}
+
+func (rt *route6Table) AddRoute(network string, v interface{}) error {
+	slot, net, err := parseNetwork6(network)
+	if err != nil {
+		return err
+	}
+	sec, rte, secID := rt.slotEntry(slot)
+
+	sec.Lock()
+	rte.rtHash[net] = v
+	sec.slotMask |= 1 << uint64(secID)
+	sec.Unlock()
+	return nil
+}
+
+func (rt *route6Table) DelRoute(network string) error {
+	slot, net, err := parseNetwork6(network)
+	if err != nil {
+		return err
+	}
+	sec, rte, secID := rt.slotEntry(slot)
+
+	sec.Lock()
+	delete(rte.rtHash, net)
+	if len(rte.rtHash) == 0 {
+		sec.slotMask &= ^(1 << uint64(secID)) //clear bit
+	}
+	sec.Unlock()
+	return nil
+}
+
+func (rt *route6Table) RouteLookup(ip NetWork6) interface{} {
+	var sec *rt6Section
+	var rte *rt6Entry
+	var bitMask uint64
+	for i := 0; i < IpSection6; i++ {
+		sec = &rt.rts[i]
+		sec.RLock()
+		bitMask = sec.slotMask
+		for j := 0; j < SectionSize6; j++ {
+			if bitMask == 0 {
+				break
+			}
+			if bitMask&1 != 0 {
+				rte = &sec.rtSec[j]
+				if v, ok := rte.rtHash[ip.and(rte.mask)]; ok {
+					sec.RUnlock()
+					return v
+				}
+			}
+			bitMask >>= 1
+		}
+		sec.RUnlock()
+	}
+	return nil
+}
+
+// IPv6ToNetWork6 convert the 16 byte ipv6 address to the lookup key
+func IPv6ToNetWork6(ip net.IP) NetWork6 {
+	var n NetWork6
+	copy(n[:], ip.To16())
+	return n
+}
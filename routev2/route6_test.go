@@ -0,0 +1,50 @@
+package routev2
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func ipv6(s string) NetWork6 {
+	return IPv6ToNetWork6(net.ParseIP(s))
+}
+
+func TestRoute6LongestMatch(t *testing.T) {
+	rt := NewRoute6Table()
+	rt.AddRoute("::/0", "default")
+	rt.AddRoute("2001:db8::/32", 32)   //section 1
+	rt.AddRoute("2001:db8:1::/48", 48) //section 1
+	rt.AddRoute("2001:db8:1::1/128", 128)
+	for ip, want := range map[string]interface{}{
+		"2001:db8:1::1": 128,
+		"2001:db8:1::2": 48,
+		"2001:db8:2::1": 32,
+		"2001:db9::1":   "default",
+	} {
+		if v := rt.RouteLookup(ipv6(ip)); v != want {
+			t.Errorf("RouteLookup(%s) = %v, want %v", ip, v, want)
+		}
+	}
+
+	rt.DelRoute("2001:db8:1::1/128")
+	if v := rt.RouteLookup(ipv6("2001:db8:1::1")); v != 48 {
+		t.Fatalf("RouteLookup after DelRoute of /128 = %v, want 48", v)
+	}
+	rt.DelRoute("::/0")
+	if v := rt.RouteLookup(ipv6("2001:db9::1")); v != nil {
+		t.Fatalf("RouteLookup after DelRoute of ::/0 = %v, want nil", v)
+	}
+}
+
+func TestRoute6RejectIPv4(t *testing.T) {
+	rt := NewRoute6Table()
+	for _, network := range []string{"10.0.0.0/8", "0.0.0.0/0", "::ffff:10.1.2.3/128"} {
+		if err := rt.AddRoute(network, 1); !errors.Is(err, ErrNotIPv6) {
+			t.Errorf("AddRoute(%s) = %v, want ErrNotIPv6", network, err)
+		}
+	}
+	if err := rt.DelRoute("10.0.0.0/8"); !errors.Is(err, ErrNotIPv6) {
+		t.Errorf("DelRoute(10.0.0.0/8) = %v, want ErrNotIPv6", err)
+	}
+}
@@ -1,15 +1,23 @@
 package routev2
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
 	maskMaxLen  = 32
+	defaultSlot = maskMaxLen
 	IpSection   = 4
 	SectionSize = 8
+
+	ctxCheckSlots = 8 //RouteLookupCtx check the ctx every ctxCheckSlots probed slots, the same as the root package
 )
 
 /*
@@ -37,105 +45,1025 @@ uint32|masklen32|maskLen31|maskLen30......
 
 还可以分段, 锁颗粒度变小，锁的使用也清晰。下面就是相关实现：
 */
+var (
+	ErrRouteNotFound = errors.New("route not found")
+	ErrNotIPv4       = errors.New("not ipv4")
+	ErrNotIPv6       = errors.New("not ipv6")
+	ErrInvalidMask   = errors.New("invalid mask")
+	ErrTableFull     = errors.New("route table is full")
+)
+
 type NetWork uint32
 type routeTable struct {
-	rts [IpSection]rtSection
+	rts     [IpSection]rtSection
+	secMask atomic.Uint32 //bit i 表示rts[i] 有路由条目(slotMask 不为0)，查找时跳过整个空的分段
+	def     rtDefault     //0.0.0.0/0 单独存放，查找时没有更长的掩码匹配就直接返回
+
+	hooksMu sync.Mutex
+	hooks   atomic.Pointer[routeHooks]
+
+	cacheOnce sync.Once
+	cache     atomic.Pointer[lookupCache] //nil if the lookup cache is not enabled
+
+	ttl expiry
+
+	probes atomic.Pointer[probeStats]  //nil if EnableProbeStats is not called
+	vars   atomic.Pointer[expvarStats] //nil if PublishExpvar is not called
+
+	freeEmpty bool //RouteTableOpts.FreeEmptyMaps, not changed after created
+
+	limit limiter
+
+	policy policyRoutes
+}
+
+type rtDefault struct {
+	sync.RWMutex
+	ok bool
+	v  interface{}
 }
 
 type rtSection struct {
 	sync.RWMutex
-	slotMask uint8 //SectionSize bit
+	slotMask atomic.Uint32 //SectionSize bit, 修改时持有写锁，可以不加锁原子读取
 	rtSec    [SectionSize]rtEntry
 }
 
 type rtEntry struct {
 	mask   uint32
-	rtHash map[NetWork]interface{}
+	rtKeys [arrayMax]NetWork       //rtArray, the first rtNum are used while rtHash is nil
+	rtVals [arrayMax]interface{}   //the values of rtKeys
+	rtNum  int                     //the number of routes in rtArray
+	rtHash map[NetWork]interface{} //nil while the routes fit in rtArray
+	peak   int                     //the max len of rtHash, used by Compact
+	holes  map[NetWork]struct{}    //the exceptions added by AddException, nil if there is none
+}
+
+// key return the key of ip in the slot, the network address of ip masked by the prefix mask
+func (rte *rtEntry) key(ip NetWork) NetWork {
+	return ip & NetWork(rte.mask)
 }
 
 func NewRouteTable() *routeTable {
+	return NewRouteTableOpts(RouteTableOpts{})
+}
+
+func NewRouteTableOpts(opts RouteTableOpts) *routeTable {
 	rt := new(routeTable)
+	rt.freeEmpty = opts.FreeEmptyMaps
 	idx := 0
 	for i := 0; i < IpSection; i++ {
 		for j := 0; j < SectionSize; j++ {
 			idx = i*SectionSize + j
 			section := &rt.rts[i]
-			section.rtSec[j].mask = (1 << (maskMaxLen - uint32(idx))) - 1
-			section.rtSec[j].rtHash = make(map[NetWork]interface{})
+			section.rtSec[j].mask = MaskForSlot(idx) //the high bits, e.g. 0xffffff00 for /24
 		}
 	}
 	return rt
 }
 
-func (rt *routeTable) AddRoute(network string, v interface{}) error {
+func parseNetwork(network string) (int, NetWork, error) {
 	_, ipnet, err := net.ParseCIDR(network)
 	if err != nil {
-		return err
+		return 0, 0, err
+	}
+	return netSlot(ipnet)
+}
+
+// netSlot never read ipnet.IP directly, To4 return nil for the short or empty ip,
+// so a malformed ipnet is reported as an error instead of panic
+func netSlot(ipnet *net.IPNet) (int, NetWork, error) {
+	if ipnet == nil {
+		return 0, 0, fmt.Errorf("%w network: nil", ErrNotIPv4)
+	}
+	ip4 := ipnet.IP.To4()
+	if ip4 == nil {
+		return 0, 0, fmt.Errorf("%w network: %v", ErrNotIPv4, ipnet)
 	}
 
-	maskLen, _ := ipnet.Mask.Size()
+	maskLen, bits := ipnet.Mask.Size()
+	if bits != 8*net.IPv4len && bits != 8*net.IPv6len {
+		//non-canonical mask like 255.0.255.0, or the mask of a wrong length
+		return 0, 0, fmt.Errorf("%w network: %v", ErrInvalidMask, ipnet)
+	}
+	if bits == 8*net.IPv6len {
+		maskLen -= 8 * (net.IPv6len - net.IPv4len)
+	}
+	if maskLen < 0 {
+		return 0, 0, fmt.Errorf("%w network: %v", ErrInvalidMask, ipnet)
+	}
 	slot := maskMaxLen - maskLen
-	net := NetWork(binary.BigEndian.Uint32(ipnet.IP))
+	//ParseCIDR already masked the ip, but AddRouteNet may be called with host bits set,
+	//mask it explicitly so the key is always what RouteLookup compute by rtEntry.key
+	return slot, NetWork(binary.BigEndian.Uint32(ip4)) & NetWork(MaskForSlot(slot)), nil
+}
+
+// setSlotBit and clearSlotBit must be called with the section of slot locked,
+// so that the slotMask and secMask bits are always consistent with the routes of the slot
+func (rt *routeTable) setSlotBit(slot int) {
+	ipID, secID := slot/SectionSize, slot&(SectionSize-1)
+	rt.rts[ipID].slotMask.Or(1 << uint32(secID))
+	rt.secMask.Or(1 << uint32(ipID))
+}
+
+func (rt *routeTable) clearSlotBit(slot int) {
+	ipID, secID := slot/SectionSize, slot&(SectionSize-1)
+	bit := uint32(1) << uint32(secID)
+	if old := rt.rts[ipID].slotMask.And(^bit); old&^bit == 0 {
+		rt.secMask.And(^(1 << uint32(ipID))) //the section become empty
+	}
+}
 
-	ipID := slot / IpSection
+func (rt *routeTable) slotEntry(slot int) (*rtSection, *rtEntry, int) {
+	ipID := slot / SectionSize
 	secID := slot & (SectionSize - 1)
 	sec := &rt.rts[ipID]
-	rte := &sec.rtSec[secID]
+	return sec, &sec.rtSec[secID], secID
+}
 
-	sec.Lock()
-	rte.rtHash[net] = v
-	sec.slotMask |= 1 << uint8(secID)
-	sec.Unlock()
-	return nil
+// NormalizeCIDR return the canonical form of the ipv4 network, the host bits are masked away,
+// e.g. "10.0.0.1/8" => "10.0.0.0/8", so callers can canonicalize the networks before insert
+func NormalizeCIDR(s string) (string, error) {
+	slot, net, err := parseNetwork(s)
+	if err != nil {
+		return "", err
+	}
+	return slotIPNet(slot, net).String(), nil
 }
 
-func (rt *routeTable) DelRoute(network string) error {
+// AddRoute add or replace the route of network, the host bits of network are masked away,
+// so "10.0.0.0/8" and "10.0.0.1/8" are the same route, use AddRouteStrict to reject the latter
+func (rt *routeTable) AddRoute(network string, v interface{}) error {
 	_, ipnet, err := net.ParseCIDR(network)
 	if err != nil {
 		return err
 	}
+	return rt.AddRouteNet(ipnet, v)
+}
 
-	maskLen, _ := ipnet.Mask.Size()
-	slot := maskMaxLen - maskLen
-	net := NetWork(binary.BigEndian.Uint32(ipnet.IP))
+// AddRouteStrict is the same as AddRoute, but reject the network whose host bits are set,
+// e.g. "10.0.0.5/8", which is usually a host address passed as a network by mistake
+func (rt *routeTable) AddRouteStrict(network string, v interface{}) error {
+	ip, ipnet, err := net.ParseCIDR(network)
+	if err != nil {
+		return err
+	}
+	if !ip.Equal(ipnet.IP) {
+		return fmt.Errorf("host bits set in network %s, should be %v", network, ipnet)
+	}
+	return rt.AddRouteNet(ipnet, v)
+}
 
-	ipID := slot / IpSection
-	secID := slot & (SectionSize - 1)
-	sec := &rt.rts[ipID]
-	rte := &sec.rtSec[secID]
+// AddRouteNet add the route without parsing the network string,
+// it return ErrInvalidMask if the mask is not contiguous, e.g. 255.0.255.0
+func (rt *routeTable) AddRouteNet(ipnet *net.IPNet, v interface{}) error {
+	slot, net, err := netSlot(ipnet)
+	if err != nil {
+		return err
+	}
+	_, _, err = rt.addRouteLimited(slot, net, v, addAlways)
+	return err
+}
+
+// AddRouteBits add the route of ip/maskLen without string parsing, the host bits of ip are masked.
+// it return ErrInvalidMask if maskLen is not in [0, 32]
+func (rt *routeTable) AddRouteBits(ip uint32, maskLen int, v interface{}) error {
+	slot, net, err := bitsSlot(ip, maskLen)
+	if err != nil {
+		return err
+	}
+	_, _, err = rt.addRouteLimited(slot, net, v, addAlways)
+	return err
+}
+
+// DelRouteBits delete the route of ip/maskLen without string parsing, the host bits of ip are masked
+func (rt *routeTable) DelRouteBits(ip uint32, maskLen int) error {
+	slot, net, err := bitsSlot(ip, maskLen)
+	if err != nil {
+		return err
+	}
+	rt.delRoute(slot, net)
+	return nil
+}
+
+func bitsSlot(ip uint32, maskLen int) (int, NetWork, error) {
+	slot, err := SlotForMask(maskLen)
+	if err != nil {
+		return 0, 0, err
+	}
+	return slot, NetWork(ip & MaskForSlot(slot)), nil
+}
+
+// RouteEntry is a route of AddRoutes, ReplaceMaskLevel and ExportByMask,
+// Exception mark an exception added by AddException, its Value is nil
+type RouteEntry struct {
+	Network   string      `json:"network"`
+	Value     interface{} `json:"value"`
+	Exception bool        `json:"exception,omitempty"`
+}
+
+// AddRoutes add routes in batch, each section is locked only once.
+// nothing is added if any network of entries is invalid.
+// on a limited table the routes are added one by one through the limiter, if one is rejected
+// with ErrTableFull, the routes added before it are kept
+func (rt *routeTable) AddRoutes(entries []RouteEntry) error {
+	var slots [IpSection * SectionSize][]rtItem
+	var defaults []interface{}
+	for i, e := range entries {
+		slot, net, err := parseNetwork(e.Network)
+		if err != nil {
+			return fmt.Errorf("entries[%d]: %w", i, err)
+		}
+		if e.Exception {
+			if slot == defaultSlot {
+				return fmt.Errorf("entries[%d]: %w: can't add exception for the default route", i, ErrInvalidMask)
+			}
+			e.Value = nil
+		}
+		if slot == defaultSlot {
+			defaults = append(defaults, e.Value)
+			continue
+		}
+		slots[slot] = append(slots[slot], rtItem{slot: slot, net: net, v: e.Value, hole: e.Exception})
+	}
+	if rt.limit.max.Load() != 0 {
+		return rt.addItemsLimited(slots[:], defaults)
+	}
+
+	hooks := rt.hooks.Load()
+	var events []RouteEvent
+	for i := 0; i < IpSection; i++ {
+		sec := &rt.rts[i]
+		locked := false
+		for j := 0; j < SectionSize; j++ {
+			items := slots[i*SectionSize+j]
+			if len(items) == 0 {
+				continue
+			}
+			if !locked {
+				sec.Lock()
+				locked = true
+			}
+			rte := &sec.rtSec[j]
+			for _, item := range items {
+				typ := RouteAdd
+				if rte.set(item.net, item.v) {
+					typ = RouteReplace
+				}
+				if item.hole {
+					rte.addHole(item.net)
+				} else {
+					delete(rte.holes, item.net)
+				}
+				rt.ttl.forget(slotKey{item.slot, item.net})
+				if hooks != nil {
+					events = append(events, RouteEvent{Type: typ, Network: slotIPNet(item.slot, item.net), Value: item.v, Exception: item.hole})
+				}
+			}
+			rte.grown()
+			rt.setSlotBit(i*SectionSize + j)
+		}
+		if locked {
+			sec.Unlock()
+		}
+	}
+	for _, e := range events {
+		hooks.call(e)
+	}
+	for _, v := range defaults {
+		rt.addRoute(defaultSlot, 0, v, addAlways)
+	}
+	return nil
+}
+
+func (rt *routeTable) addItemsLimited(slots [][]rtItem, defaults []interface{}) error {
+	for _, items := range slots {
+		for _, item := range items {
+			var err error
+			if item.hole {
+				err = rt.addException(item.slot, item.net)
+			} else {
+				_, _, err = rt.addRouteLimited(item.slot, item.net, item.v, addAlways)
+			}
+			if err != nil {
+				return fmt.Errorf("%v: %w", slotIPNet(item.slot, item.net), err)
+			}
+		}
+	}
+	for _, v := range defaults {
+		if _, _, err := rt.addRouteLimited(defaultSlot, 0, v, addAlways); err != nil {
+			return fmt.Errorf("%v: %w", slotIPNet(defaultSlot, 0), err)
+		}
+	}
+	return nil
+}
 
-	sec.Lock()
-	delete(rte.rtHash, net)
-	if len(rte.rtHash) == 0 {
-		sec.slotMask &= ^(1 << uint8(secID)) //clear bit
+// ReplaceRoute add the route like AddRoute, and return the old value if the network existed before
+func (rt *routeTable) ReplaceRoute(network string, v interface{}) (old interface{}, existed bool, err error) {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		return nil, false, err
+	}
+	return rt.addRouteLimited(slot, net, v, addAlways)
+}
+
+type addMode int
+
+const (
+	addAlways   addMode = iota
+	addIfExist          //only update the route that existed
+	addIfAbsent         //only add the route that didn't exist
+)
+
+func (m addMode) store(existed bool) bool {
+	return m == addAlways || (m == addIfExist && existed) || (m == addIfAbsent && !existed)
+}
+
+// AddRouteIfAbsent add the route only if the network doesn't exist, the check and the add are atomic.
+// if the network existed, nothing is modified and the existing value is returned.
+// an exception added by AddException counts as existed
+func (rt *routeTable) AddRouteIfAbsent(network string, v interface{}) (installed bool, existing interface{}, err error) {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		return false, existing, err
+	}
+	existing, existed, err := rt.addRouteLimited(slot, net, v, addIfAbsent)
+	return !existed && err == nil, existing, err
+}
+
+// UpdateRoute update the value of the network, return ErrRouteNotFound if the network doesn't exist
+func (rt *routeTable) UpdateRoute(network string, v interface{}) error {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		return err
+	}
+	//an exception is not found like GetRoute, it's replaced by AddRoute only
+	var rte *rtEntry //the default slot has no exception
+	if slot != defaultSlot {
+		_, rte, _ = rt.slotEntry(slot)
+	}
+	updated := false
+	rt.modify(slot, net, func(old interface{}, existed bool) (interface{}, routeOp) {
+		if !existed || (rte != nil && rte.hole(net)) {
+			return old, opNone
+		}
+		updated = true
+		return v, opStore
+	})
+	if !updated {
+		return ErrRouteNotFound
 	}
-	sec.Unlock()
 	return nil
 }
 
+func (rt *routeTable) addRoute(slot int, net NetWork, v interface{}, mode addMode) (interface{}, bool) {
+	return rt.modify(slot, net, addFn(v, mode))
+}
+
+// addFn return the fn of modify that store v according to mode
+func addFn(v interface{}, mode addMode) func(old interface{}, existed bool) (interface{}, routeOp) {
+	return func(old interface{}, existed bool) (interface{}, routeOp) {
+		if mode.store(existed) {
+			return v, opStore
+		}
+		return old, opNone
+	}
+}
+
+type routeOp int
+
+const (
+	opNone routeOp = iota
+	opStore
+	opDelete
+	opHole //store the exception, the value is nil
+)
+
+// modify store or delete the route of the network according to what fn return, and return the old route.
+// fn is called with the section locked, so it must not call back into rt.
+// the change is notified to the OnChange hooks after unlocked
+func (rt *routeTable) modify(slot int, net NetWork, fn func(old interface{}, existed bool) (v interface{}, op routeOp)) (interface{}, bool) {
+	return rt.modifyTTL(slot, net, time.Time{}, fn)
+}
+
+// modifyTTL is modify that set the expiry deadline of the route to at if it's stored,
+// the zero at drop the deadline of the stored or deleted route
+func (rt *routeTable) modifyTTL(slot int, net NetWork, at time.Time, fn func(old interface{}, existed bool) (v interface{}, op routeOp)) (interface{}, bool) {
+	old, existed, evt := rt.modifyEvent(slot, net, at, fn)
+	rt.emit(evt)
+	return old, existed
+}
+
+// modifyEvent is modifyTTL that return the change instead of notifying it,
+// for the callers that hold another lock the hooks may need, they emit it after unlocked
+func (rt *routeTable) modifyEvent(slot int, net NetWork, at time.Time, fn func(old interface{}, existed bool) (v interface{}, op routeOp)) (old interface{}, existed bool, evt pendingEvent) {
+	var v interface{}
+	var op routeOp
+	var wasHole bool
+	if slot == defaultSlot {
+		rt.def.Lock()
+		old, existed = rt.def.v, rt.def.ok
+		v, op = fn(old, existed)
+		switch op {
+		case opStore:
+			rt.def.v, rt.def.ok = v, true
+		case opDelete:
+			rt.def.v, rt.def.ok = nil, false
+		}
+		if op != opNone {
+			rt.ttl.set(slotKey{slot, net}, at)
+		}
+		rt.def.Unlock()
+	} else {
+		sec, rte, _ := rt.slotEntry(slot)
+		sec.Lock()
+		old, existed = rte.get(net)
+		wasHole = rte.hole(net)
+		v, op = fn(old, existed)
+		switch op {
+		case opStore, opHole:
+			rte.set(net, v)
+			if op == opHole {
+				rte.addHole(net)
+			} else {
+				delete(rte.holes, net)
+			}
+			rte.grown()
+			rt.setSlotBit(slot)
+		case opDelete:
+			if existed {
+				rte.del(net)
+				delete(rte.holes, net)
+				if rte.count() == 0 {
+					rt.clearSlotBit(slot)
+					rt.freeHash(rte)
+				}
+			}
+		}
+		if op != opNone {
+			rt.ttl.set(slotKey{slot, net}, at)
+		}
+		sec.Unlock()
+	}
+
+	switch {
+	case (op == opStore || op == opHole) && existed:
+		evt = pendingEvent{typ: RouteReplace, slot: slot, net: net, v: v, hole: op == opHole, ok: true}
+	case op == opStore || op == opHole:
+		evt = pendingEvent{typ: RouteAdd, slot: slot, net: net, v: v, hole: op == opHole, ok: true}
+	case op == opDelete && existed:
+		evt = pendingEvent{typ: RouteDel, slot: slot, net: net, v: old, hole: wasHole, ok: true}
+	}
+	return old, existed, evt
+}
+
+// GetRoute return the value of the network exactly, without longest prefix matching
+func (rt *routeTable) GetRoute(network string) (interface{}, bool, error) {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		return nil, false, err
+	}
+	v, ok := rt.getRoute(slot, net)
+	return v, ok, nil
+}
+
+func (rt *routeTable) getRoute(slot int, net NetWork) (interface{}, bool) {
+	if slot == defaultSlot {
+		_, _, v, ok := rt.lookupDefault()
+		return v, ok
+	}
+	sec, rte, _ := rt.slotEntry(slot)
+
+	sec.RLock()
+	v, ok := rte.get(net)
+	hole := rte.hole(net)
+	sec.RUnlock()
+	return v, ok && !hole
+}
+
+func (rt *routeTable) DelRoute(network string) error {
+	_, err := rt.DelRouteReport(network)
+	return err
+}
+
+// DelRouteReport delete the route and report whether the route existed and was removed
+func (rt *routeTable) DelRouteReport(network string) (removed bool, err error) {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		return false, err
+	}
+	return rt.delRoute(slot, net), nil
+}
+
+func (rt *routeTable) delRoute(slot int, net NetWork) bool {
+	existed, evt := rt.delRouteEvent(slot, net)
+	rt.emit(evt)
+	return existed
+}
+
+// delRouteEvent is delRoute that return the change instead of notifying it
+func (rt *routeTable) delRouteEvent(slot int, net NetWork) (bool, pendingEvent) {
+	_, existed, evt := rt.modifyEvent(slot, net, time.Time{}, func(old interface{}, existed bool) (interface{}, routeOp) {
+		return old, opDelete
+	})
+	return existed, evt
+}
+
+// Clear drop all routes and the policy routes, it lock section by section, so it's safe to call with lookup
+func (rt *routeTable) Clear() {
+	hooks := rt.hooks.Load()
+	for i := 0; i < IpSection; i++ {
+		var old []rtItem
+		sec := &rt.rts[i]
+		sec.Lock()
+		for j := 0; j < SectionSize; j++ {
+			rte := &sec.rtSec[j]
+			if rte.count() > 0 {
+				if hooks != nil {
+					for net, v := range rte.all() {
+						old = append(old, rtItem{slot: i*SectionSize + j, net: net, v: v, hole: rte.hole(net)})
+					}
+				}
+				rte.resetArray()
+				rte.rtHash = nil
+				rte.holes = nil
+				rte.peak = 0
+				rt.ttl.forgetSlot(i*SectionSize + j)
+			}
+		}
+		sec.slotMask.Store(0)
+		rt.secMask.And(^(1 << uint32(i)))
+		sec.Unlock()
+
+		for _, item := range old {
+			hooks.call(RouteEvent{Type: RouteDel, Network: slotIPNet(item.slot, item.net), Value: item.v, Exception: item.hole})
+		}
+	}
+	rt.delRoute(defaultSlot, 0)
+	rt.clearPolicy()
+}
+
+// Clone deep copy the table section by section, the returned table is independent of rt
+func (rt *routeTable) Clone() *routeTable {
+	c := NewRouteTableOpts(RouteTableOpts{FreeEmptyMaps: rt.freeEmpty})
+	for i := 0; i < IpSection; i++ {
+		sec, csec := &rt.rts[i], &c.rts[i]
+		sec.RLock()
+		for j := 0; j < SectionSize; j++ {
+			for net, v := range sec.rtSec[j].all() {
+				csec.rtSec[j].set(net, v)
+			}
+			for net := range sec.rtSec[j].holes {
+				csec.rtSec[j].addHole(net)
+			}
+		}
+		csec.slotMask.Store(sec.slotMask.Load())
+		if sec.slotMask.Load() != 0 {
+			c.secMask.Or(1 << uint32(i))
+		}
+		sec.RUnlock()
+	}
+
+	rt.def.RLock()
+	c.def.v, c.def.ok = rt.def.v, rt.def.ok
+	rt.def.RUnlock()
+	rt.copyLimit(c)
+	return c
+}
+
+func (rt *routeTable) Count() int {
+	n := 0
+	for i := 0; i < IpSection; i++ {
+		sec := &rt.rts[i]
+		sec.RLock()
+		for j := 0; j < SectionSize; j++ {
+			n += sec.rtSec[j].count()
+		}
+		sec.RUnlock()
+	}
+	if _, _, _, ok := rt.lookupDefault(); ok {
+		n++
+	}
+	return n
+}
+
+func (rt *routeTable) CountByMask(maskLen int) int {
+	slot, err := SlotForMask(maskLen)
+	if err != nil {
+		return 0
+	}
+	if slot == defaultSlot {
+		if _, _, _, ok := rt.lookupDefault(); ok {
+			return 1
+		}
+		return 0
+	}
+	sec, rte, _ := rt.slotEntry(slot)
+
+	sec.RLock()
+	n := rte.count()
+	sec.RUnlock()
+	return n
+}
+
+type rtItem struct {
+	slot int
+	net  NetWork
+	v    interface{}
+	hole bool //an exception added by AddException, v is nil
+}
+
+func (rt *routeTable) snapshot(ipID int) []rtItem {
+	var items []rtItem
+	sec := &rt.rts[ipID]
+	sec.RLock()
+	for j := 0; j < SectionSize; j++ {
+		for net, v := range sec.rtSec[j].all() {
+			items = append(items, rtItem{slot: ipID*SectionSize + j, net: net, v: v, hole: sec.rtSec[j].hole(net)})
+		}
+	}
+	sec.RUnlock()
+	return items
+}
+
+func (rt *routeTable) snapshotSlot(slot int) []rtItem {
+	if slot == defaultSlot {
+		if _, _, v, ok := rt.lookupDefault(); ok {
+			return []rtItem{{slot: slot, v: v}}
+		}
+		return nil
+	}
+	sec, rte, _ := rt.slotEntry(slot)
+	sec.RLock()
+	items := make([]rtItem, 0, rte.count())
+	for net, v := range rte.all() {
+		items = append(items, rtItem{slot: slot, net: net, v: v, hole: rte.hole(net)})
+	}
+	sec.RUnlock()
+	return items
+}
+
+// dropHoles remove the exceptions from items in place, the walkers skip them like RangeScan
+func dropHoles(items []rtItem) []rtItem {
+	n := 0
+	for _, item := range items {
+		if !item.hole {
+			items[n] = item
+			n++
+		}
+	}
+	return items[:n]
+}
+
+// Walk iterate all routes from the longest mask to the shortest, stop if fn return false.
+// each section is copied under its lock, so fn is called without holding any lock. the exceptions are skipped
+func (rt *routeTable) Walk(fn func(network *net.IPNet, v interface{}) bool) {
+	for i := 0; i < IpSection; i++ {
+		for _, item := range dropHoles(rt.snapshot(i)) {
+			if !fn(slotIPNet(item.slot, item.net), item.v) {
+				return
+			}
+		}
+	}
+	for _, item := range rt.snapshotSlot(defaultSlot) {
+		fn(slotIPNet(item.slot, item.net), item.v)
+	}
+}
+
+// FindByValue return all networks whose value satisfy match, it walks the whole table
+func (rt *routeTable) FindByValue(match func(v interface{}) bool) []*net.IPNet {
+	var networks []*net.IPNet
+	rt.Walk(func(network *net.IPNet, v interface{}) bool {
+		if match(v) {
+			networks = append(networks, network)
+		}
+		return true
+	})
+	return networks
+}
+
 func (rt *routeTable) RouteLookup(ip NetWork) interface{} {
+	v, _ := rt.RouteLookupOK(ip)
+	return v
+}
+
+// RouteLookupOK report whether a route matched, so a route with nil value can be distinguished from no route
+func (rt *routeTable) RouteLookupOK(ip NetWork) (interface{}, bool) {
+	var v interface{}
+	var ok bool
+	if c := rt.cache.Load(); c != nil {
+		v, ok = rt.cachedLookup(c, ip)
+	} else {
+		_, _, v, ok = rt.lookup(ip)
+	}
+	rt.recordLookup(ok)
+	return v, ok
+}
+
+// LookupHost only match the /32 host route of ip, it skip the slotMask walk,
+// for the callers that know they want a host route
+func (rt *routeTable) LookupHost(ip NetWork) (interface{}, bool) {
+	sec, rte, _ := rt.slotEntry(0)
+	sec.RLock()
+	v, ok := rte.get(ip)
+	hole := rte.hole(ip)
+	sec.RUnlock()
+	return v, ok && !hole
+}
+
+// RouteLookupNoDefault is RouteLookupOK that ignore the default route, the slot walk stop before /0.
+// it doesn't use the lookup cache, the cached result may be the default route
+func (rt *routeTable) RouteLookupNoDefault(ip NetWork) (interface{}, bool) {
+	_, _, v, ok := rt.lookupSlots(ip, 0, defaultSlot-1)
+	return v, ok
+}
+
+// RouteLookupIP accept both 4 byte and 16 byte(ipv4-in-ipv6) form of ipv4 address,
+// return nil if ip is not ipv4 or there is no route matched
+func (rt *routeTable) RouteLookupIP(ip net.IP) interface{} {
+	n, err := ipToNetWork(ip)
+	if err != nil {
+		return nil
+	}
+	return rt.RouteLookup(n)
+}
+
+func (rt *routeTable) RouteLookupString(s string) (interface{}, error) {
+	n, err := ipToNetWork(net.ParseIP(s))
+	if err != nil {
+		return nil, err
+	}
+	return rt.RouteLookup(n), nil
+}
+
+// IPv4ToNetWork convert ip to the lookup key, NetWork is the big-endian(network byte order)
+// value of the ipv4 address, the same as AddRoute stores. it return 0 if ip is not ipv4
+func IPv4ToNetWork(ip net.IP) NetWork {
+	n, _ := ipToNetWork(ip)
+	return n
+}
+
+// NetWorkToIP convert the big-endian NetWork back to the 4 byte ipv4 address
+func NetWorkToIP(n NetWork) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, uint32(n))
+	return ip
+}
+
+func ipToNetWork(ip net.IP) (NetWork, error) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return 0, fmt.Errorf("%w address: %v", ErrNotIPv4, ip)
+	}
+	return NetWork(binary.BigEndian.Uint32(ip4)), nil
+}
+
+// RouteLookupEntry return the matched network as well as the value
+func (rt *routeTable) RouteLookupEntry(ip NetWork) (network *net.IPNet, v interface{}, ok bool) {
+	slot, net, v, ok := rt.lookup(ip)
+	if !ok {
+		return nil, nil, false
+	}
+	return slotIPNet(slot, net), v, true
+}
+
+// RouteLookupMask write the mask of the matched route into *outMask, it doesn't allocate
+// if *outMask has the capacity of 4 bytes. *outMask is not changed if no route matched
+func (rt *routeTable) RouteLookupMask(ip NetWork, outMask *net.IPMask) (interface{}, bool) {
+	slot, _, v, ok := rt.lookup(ip)
+	if !ok {
+		return v, false
+	}
+	if cap(*outMask) < net.IPv4len {
+		*outMask = make(net.IPMask, net.IPv4len)
+	}
+	*outMask = (*outMask)[:net.IPv4len]
+	binary.BigEndian.PutUint32(*outMask, MaskForSlot(slot))
+	return v, true
+}
+
+// MatchResult is filled by RouteLookupInto, so the caller can reuse it without allocation
+type MatchResult struct {
+	Value   interface{}
+	MaskLen int
+}
+
+// RouteLookupInto fill out with the matched route, it doesn't allocate
+func (rt *routeTable) RouteLookupInto(ip NetWork, out *MatchResult) bool {
+	slot, _, v, ok := rt.lookup(ip)
+	if !ok {
+		return false
+	}
+	out.Value, out.MaskLen = v, maskMaxLen-slot
+	return true
+}
+
+// RouteLookupN probe at most maxProbes slots that have routes, the default route counts as one probe.
+// exhausted is true if the budget ran out before all the slots were probed, then ok is false
+// even if a shorter route may match. maxProbes < 0 means no limit, the same as RouteLookupOK
+func (rt *routeTable) RouteLookupN(ip NetWork, maxProbes int) (v interface{}, ok bool, exhausted bool) {
+	probes := 0
+	rt.probe(ip, func(_ int, _ NetWork, rv interface{}, found, hole bool) bool {
+		if probes == maxProbes {
+			exhausted = true
+			return false
+		}
+		probes++
+		if found && !hole {
+			v, ok = rv, true
+		}
+		return !found
+	})
+	return v, ok, exhausted
+}
+
+// RouteLookupCtx is RouteLookup that check ctx every ctxCheckSlots probed slots during the slot walk,
+// and return ctx.Err() if ctx is done before a route is matched
+func (rt *routeTable) RouteLookupCtx(ctx context.Context, ip NetWork) (v interface{}, ok bool, err error) {
+	probes := 0
+	rt.probe(ip, func(_ int, _ NetWork, rv interface{}, found, hole bool) bool {
+		if probes%ctxCheckSlots == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+		probes++
+		if found && !hole {
+			v, ok = rv, true
+		}
+		return !found
+	})
+	return v, ok, err
+}
+
+// RouteLookupPrimaryBackup return the longest match as primary, and the next less specific match
+// as backup in the same slot walk, so the backup next hop can be pre-staged for failover
+func (rt *routeTable) RouteLookupPrimaryBackup(ip NetWork) (primary, backup interface{}, okPrimary, okBackup bool) {
+	rt.match(ip, func(slot int, net NetWork, v interface{}) bool {
+		if !okPrimary {
+			primary, okPrimary = v, true
+			return true
+		}
+		backup, okBackup = v, true
+		return false
+	})
+	return primary, backup, okPrimary, okBackup
+}
+
+// RouteLookupMinMask only match the routes whose mask length >= minMaskLen
+func (rt *routeTable) RouteLookupMinMask(ip NetWork, minMaskLen int) (interface{}, bool) {
+	if minMaskLen > maskMaxLen {
+		return nil, false
+	}
+	if minMaskLen < 0 {
+		minMaskLen = 0
+	}
+	_, _, v, ok := rt.lookupSlots(ip, 0, maskMaxLen-minMaskLen)
+	return v, ok
+}
+
+// Contains report whether any route match ip
+func (rt *routeTable) Contains(ip NetWork) bool {
+	_, _, _, ok := rt.lookup(ip)
+	return ok
+}
+
+// RouteLookupWithMask return the mask length of the matched route as well as the value
+func (rt *routeTable) RouteLookupWithMask(ip NetWork) (v interface{}, maskLen int, ok bool) {
+	slot, _, v, ok := rt.lookup(ip)
+	if !ok {
+		return v, 0, false
+	}
+	return v, maskMaxLen - slot, true
+}
+
+type MatchedRoute struct {
+	Network *net.IPNet
+	Value   interface{}
+}
+
+// RouteLookupAll return all routes that contain ip, the longest prefix first
+func (rt *routeTable) RouteLookupAll(ip NetWork) []MatchedRoute {
+	var routes []MatchedRoute
+	rt.match(ip, func(slot int, net NetWork, v interface{}) bool {
+		routes = append(routes, MatchedRoute{Network: slotIPNet(slot, net), Value: v})
+		return true
+	})
+	return routes
+}
+
+func slotIPNet(slot int, key NetWork) *net.IPNet {
+	return &net.IPNet{IP: NetWorkToIP(key), Mask: net.CIDRMask(maskMaxLen-slot, maskMaxLen)}
+}
+
+func (rt *routeTable) lookup(ip NetWork) (int, NetWork, interface{}, bool) {
+	return rt.lookupSlots(ip, 0, defaultSlot)
+}
+
+// lookupSlots do the longest prefix matching only in the slots from minSlot to maxSlot.
+// the slot is probed before the section is unlocked, otherwise a concurrent DelRoute
+// may be writing the map that the slotMask just said to probe
+func (rt *routeTable) lookupSlots(ip NetWork, minSlot, maxSlot int) (int, NetWork, interface{}, bool) {
 	var sec *rtSection
 	var rte *rtEntry
 	var net NetWork
-	var bitMask uint8
-	for i := 0; i < IpSection; i++ {
+	var bitMask uint32
+	last := maxSlot
+	if last >= defaultSlot {
+		last = defaultSlot - 1
+	}
+	probes := 0
+	secMask := rt.secMask.Load()
+	for i := minSlot / SectionSize; i <= last/SectionSize; i++ {
+		if secMask&(1<<uint32(i)) == 0 {
+			continue //skip the empty section without locking
+		}
 		sec = &rt.rts[i]
+		j, end := 0, SectionSize-1
+		if i == minSlot/SectionSize {
+			j = minSlot % SectionSize
+		}
+		if i == last/SectionSize {
+			end = last % SectionSize
+		}
+		//secMask is loaded once before the loop, load slotMask again to skip the section
+		//that become empty since then, or that has no route in [j, end]
+		if sec.slotMask.Load()>>uint32(j)&(1<<uint32(end-j+1)-1) == 0 {
+			continue
+		}
 		sec.RLock()
-		bitMask = sec.slotMask
-		sec.Unlock()
-		for j := 0; j < SectionSize; j++ {
+		bitMask = sec.slotMask.Load() >> uint32(j)
+		for ; j <= end; j++ {
 			if bitMask == 0 {
 				break
 			}
 			if bitMask&1 != 0 {
+				probes++
 				rte = &sec.rtSec[j]
-				net = NetWork(rte.mask) & ip
-				if v, ok := rte.rtHash[net]; ok {
-					return v
+				net = rte.key(ip)
+				if v, ok := rte.get(net); ok {
+					hole := rte.hole(net)
+					sec.RUnlock()
+					rt.recordProbes(probes)
+					return i*SectionSize + j, net, v, !hole //the exception hide the shorter routes
 				}
 			}
 			bitMask >>= 1
 		}
+		sec.RUnlock()
 	}
-	return nil
+	rt.recordProbes(probes)
+	if maxSlot >= defaultSlot {
+		return rt.lookupDefault()
+	}
+	return 0, 0, nil, false
+}
+
+// match call fn for each slot that contain ip, from the longest mask to the shortest,
+// stop if fn return false. an exception stop the match without calling fn, fn is called with the section read locked
+func (rt *routeTable) match(ip NetWork, fn func(slot int, net NetWork, v interface{}) bool) {
+	rt.probe(ip, func(slot int, net NetWork, v interface{}, ok, hole bool) bool {
+		if hole {
+			return false
+		}
+		return !ok || fn(slot, net, v)
+	})
+}
+
+// probe call fn for each slot that has routes and the default route, from the longest mask to the shortest,
+// ok report whether the slot has the route of ip, hole whether the route is an exception.
+// stop if fn return false, fn is called with the section read locked, so it must not modify rt
+func (rt *routeTable) probe(ip NetWork, fn func(slot int, net NetWork, v interface{}, ok, hole bool) bool) {
+	secMask := rt.secMask.Load()
+	for i := 0; i < IpSection; i++ {
+		if secMask&(1<<uint32(i)) == 0 {
+			continue
+		}
+		sec := &rt.rts[i]
+		sec.RLock()
+		bitMask := sec.slotMask.Load()
+		for j := 0; bitMask != 0; j++ {
+			if bitMask&1 != 0 {
+				rte := &sec.rtSec[j]
+				net := rte.key(ip)
+				v, ok := rte.get(net)
+				if !fn(i*SectionSize+j, net, v, ok, rte.hole(net)) {
+					sec.RUnlock()
+					return
+				}
+			}
+			bitMask >>= 1
+		}
+		sec.RUnlock()
+	}
+	if _, _, v, ok := rt.lookupDefault(); ok {
+		fn(defaultSlot, 0, v, true, false)
+	}
+}
+
+func (rt *routeTable) lookupDefault() (int, NetWork, interface{}, bool) {
+	rt.def.RLock()
+	v, ok := rt.def.v, rt.def.ok
+	rt.def.RUnlock()
+	return defaultSlot, 0, v, ok
 }
@@ -0,0 +1,134 @@
+package routev2
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+/*
+shardedTable 把每个掩码长度的rtHash 再按网络地址的哈希拆成多个分片，每个分片有自己的锁，
+适合某一种掩码长度(比如/24)有上百万条路由的场景，这时routeTable 的修改都集中在一个分段锁上。
+不再按分段组织，slotMask 用uint64 表示33个槽(包括默认路由)哪个有路由条目。
+*/
+type shardedTable struct {
+	maskMu   sync.Mutex    //serialize the updates of slotMask
+	slotMask atomic.Uint64 //bit defaultSlot is the default route
+	slots    [defaultSlot + 1]shardedSlot
+}
+
+type shardedSlot struct {
+	mask   uint32
+	n      atomic.Int64 //the number of routes in all shards
+	shards []rtShard
+}
+
+type rtShard struct {
+	sync.RWMutex
+	rtHash map[NetWork]interface{}
+}
+
+// NewRouteTableSharded return a table that split each mask length into shardsPerSlot shards
+func NewRouteTableSharded(shardsPerSlot int) *shardedTable {
+	if shardsPerSlot < 1 {
+		shardsPerSlot = 1
+	}
+	rt := new(shardedTable)
+	for i := range rt.slots {
+		sl := &rt.slots[i]
+		sl.mask = MaskForSlot(i)
+		sl.shards = make([]rtShard, shardsPerSlot)
+		for j := range sl.shards {
+			sl.shards[j].rtHash = make(map[NetWork]interface{})
+		}
+	}
+	return rt
+}
+
+var _ Table = (*shardedTable)(nil)
+
+func (sl *shardedSlot) shard(net NetWork) *rtShard {
+	h := uint32(net) * 2654435761 //fibonacci hashing, the low bits of net are 0 for the short masks
+	return &sl.shards[int(h>>16)%len(sl.shards)]
+}
+
+func (rt *shardedTable) AddRoute(network string, v interface{}) error {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		return err
+	}
+
+	sl := &rt.slots[slot]
+	sh := sl.shard(net)
+	sh.Lock()
+	_, existed := sh.rtHash[net]
+	sh.rtHash[net] = v
+	sh.Unlock()
+	if !existed {
+		//not only the add from 0 to 1: a concurrent add may have done it but not set the bit yet,
+		//and RouteLookup must see the route once AddRoute return
+		sl.n.Add(1)
+		if rt.slotMask.Load()&(uint64(1)<<uint64(slot)) == 0 {
+			rt.updateSlotBit(slot)
+		}
+	}
+	return nil
+}
+
+func (rt *shardedTable) DelRoute(network string) error {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		return err
+	}
+
+	sl := &rt.slots[slot]
+	sh := sl.shard(net)
+	sh.Lock()
+	_, existed := sh.rtHash[net]
+	delete(sh.rtHash, net)
+	sh.Unlock()
+	if existed && sl.n.Add(-1) == 0 {
+		rt.updateSlotBit(slot)
+	}
+	return nil
+}
+
+// updateSlotBit is called after the number of routes in slot dropped to 0, or an add found the bit not set.
+// the shards of a slot are locked separately, so the bit is recomputed from the count
+// under maskMu, the last update always see the final count
+func (rt *shardedTable) updateSlotBit(slot int) {
+	rt.maskMu.Lock()
+	bit := uint64(1) << uint64(slot)
+	if rt.slots[slot].n.Load() > 0 {
+		rt.slotMask.Store(rt.slotMask.Load() | bit)
+	} else {
+		rt.slotMask.Store(rt.slotMask.Load() &^ bit)
+	}
+	rt.maskMu.Unlock()
+}
+
+func (rt *shardedTable) RouteLookup(ip NetWork) interface{} {
+	rtMask := rt.slotMask.Load()
+	for i := 0; rtMask != 0; i++ {
+		if rtMask&1 != 0 {
+			sl := &rt.slots[i]
+			net := ip & NetWork(sl.mask)
+			sh := sl.shard(net)
+			sh.RLock()
+			v, ok := sh.rtHash[net]
+			sh.RUnlock()
+			if ok {
+				return v
+			}
+		}
+		rtMask >>= 1
+	}
+	return nil
+}
+
+func (rt *shardedTable) Count() int {
+	n := 0
+	for i := range rt.slots {
+		n += int(rt.slots[i].n.Load())
+	}
+	return n
+}
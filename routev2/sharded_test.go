@@ -0,0 +1,80 @@
+package routev2
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// checkShardedSlotMask check the slot bit is set iff the slot has routes
+func checkShardedSlotMask(t *testing.T, rt *shardedTable) {
+	t.Helper()
+	mask := rt.slotMask.Load()
+	for i := range rt.slots {
+		has := rt.slots[i].n.Load() > 0
+		if bit := mask&(uint64(1)<<uint64(i)) != 0; bit != has {
+			t.Fatalf("slot %d: bit %v, %d routes", i, bit, rt.slots[i].n.Load())
+		}
+	}
+}
+
+func TestShardedAddLookupDel(t *testing.T) {
+	rt := NewRouteTableSharded(4)
+	rt.AddRoute("0.0.0.0/0", "default")
+	rt.AddRoute("10.0.0.0/8", 8)
+	rt.AddRoute("10.1.2.0/24", 24)
+	rt.AddRoute("10.1.2.0/24", 25) //replace
+	if n := rt.Count(); n != 3 {
+		t.Fatalf("Count = %d, want 3", n)
+	}
+	for ip, want := range map[string]interface{}{"10.1.2.3": 25, "10.2.0.1": 8, "11.0.0.1": "default"} {
+		if v := rt.RouteLookup(ipv4(ip)); v != want {
+			t.Errorf("RouteLookup(%s) = %v, want %v", ip, v, want)
+		}
+	}
+	checkShardedSlotMask(t, rt)
+
+	rt.DelRoute("10.1.2.0/24")
+	rt.DelRoute("0.0.0.0/0")
+	if v := rt.RouteLookup(ipv4("10.1.2.3")); v != 8 {
+		t.Fatalf("RouteLookup after DelRoute = %v, want 8", v)
+	}
+	if v := rt.RouteLookup(ipv4("11.0.0.1")); v != nil {
+		t.Fatalf("RouteLookup after DelRoute of the default = %v, want nil", v)
+	}
+	if n := rt.Count(); n != 1 {
+		t.Fatalf("Count = %d, want 1", n)
+	}
+	checkShardedSlotMask(t, rt)
+}
+
+// TestShardedConcurrentAdd add the first routes of a slot concurrently, each AddRoute must be
+// visible to RouteLookup as soon as it return, not only the one that take the count from 0 to 1
+func TestShardedConcurrentAdd(t *testing.T) {
+	for round := 0; round < 100; round++ {
+		rt := NewRouteTableSharded(8)
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				rt.AddRoute(fmt.Sprintf("10.%d.0.0/16", g), g)
+				if v := rt.RouteLookup(ipv4(fmt.Sprintf("10.%d.1.1", g))); v != g {
+					t.Errorf("round %d: RouteLookup right after AddRoute = %v, want %d", round, v, g)
+				}
+			}(g)
+		}
+		wg.Wait()
+		checkShardedSlotMask(t, rt)
+
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				rt.DelRoute(fmt.Sprintf("10.%d.0.0/16", g))
+			}(g)
+		}
+		wg.Wait()
+		checkShardedSlotMask(t, rt)
+	}
+}
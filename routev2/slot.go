@@ -0,0 +1,21 @@
+package routev2
+
+import "fmt"
+
+// SlotForMask return the slot that AddRoute store the routes of maskLen in,
+// /32 is slot 0 and /0 is the last slot
+func SlotForMask(maskLen int) (int, error) {
+	if maskLen < 0 || maskLen > maskMaxLen {
+		return 0, fmt.Errorf("%w length: %d", ErrInvalidMask, maskLen)
+	}
+	return maskMaxLen - maskLen, nil
+}
+
+// MaskForSlot return the network mask of slot, the key of a route is its network address
+// masked by it, e.g. 0xffffff00 for slot 8(/24). it return 0 for the slot of /0 or an invalid slot
+func MaskForSlot(slot int) uint32 {
+	if slot < 0 || slot >= defaultSlot {
+		return 0
+	}
+	return ^uint32(0) << uint32(slot)
+}
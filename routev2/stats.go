@@ -0,0 +1,64 @@
+package routev2
+
+// RouteStats is the summary of the table returned by Stats
+type RouteStats struct {
+	Total         int
+	PerMask       [maskMaxLen + 1]int //indexed by mask length
+	OccupiedSlots int
+	SlotMask      uint64 //bit slot is set if the slot has routes, bit defaultSlot is the default route
+
+	CacheHits   uint64 //the lookup cache stats, 0 if EnableLookupCache is not called
+	CacheMisses uint64
+}
+
+// Stats gather the stats of the table, each section is read under its read lock
+func (rt *routeTable) Stats() RouteStats {
+	var st RouteStats
+	for i := 0; i < IpSection; i++ {
+		sec := &rt.rts[i]
+		sec.RLock()
+		for j := 0; j < SectionSize; j++ {
+			st.add(i*SectionSize+j, sec.rtSec[j].count())
+		}
+		sec.RUnlock()
+	}
+	if _, _, _, ok := rt.lookupDefault(); ok {
+		st.add(defaultSlot, 1)
+	}
+	if c := rt.cache.Load(); c != nil {
+		st.CacheHits, st.CacheMisses = c.hits.Load(), c.misses.Load()
+	}
+	return st
+}
+
+func (st *RouteStats) add(slot, n int) {
+	if n == 0 {
+		return
+	}
+	st.Total += n
+	st.PerMask[maskMaxLen-slot] = n
+	st.OccupiedSlots++
+	st.SlotMask |= 1 << uint64(slot)
+}
+
+// ActiveMaskLengths return the mask lengths that have routes, from the longest to the shortest
+// like the lookup order. it only read the slotMask bits of each section, without locking any section
+func (rt *routeTable) ActiveMaskLengths() []int {
+	var lens []int
+	secMask := rt.secMask.Load()
+	for i := 0; i < IpSection; i++ {
+		if secMask&(1<<uint32(i)) == 0 {
+			continue
+		}
+		for j, mask := 0, rt.rts[i].slotMask.Load(); mask != 0; j++ {
+			if mask&1 != 0 {
+				lens = append(lens, maskMaxLen-(i*SectionSize+j))
+			}
+			mask >>= 1
+		}
+	}
+	if _, _, _, ok := rt.lookupDefault(); ok {
+		lens = append(lens, 0)
+	}
+	return lens
+}
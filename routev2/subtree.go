@@ -0,0 +1,75 @@
+package routev2
+
+import "net"
+
+// DelSubtree delete all routes contained in network, including network itself,
+// e.g. DelSubtree("10.1.0.0/16") delete 10.1.0.0/16, 10.1.2.0/24, 10.1.2.3/32 and so on.
+// it return the number of routes removed
+func (rt *routeTable) DelSubtree(network string) (removed int, err error) {
+	slot, super, err := parseNetwork(network)
+	if err != nil {
+		return 0, err
+	}
+
+	superMask := NetWork(MaskForSlot(slot))
+	for i := 0; i < IpSection && i*SectionSize <= slot; i++ {
+		var items []rtItem
+		sec := &rt.rts[i]
+		sec.Lock()
+		for j := 0; j < SectionSize && i*SectionSize+j <= slot; j++ {
+			rte := &sec.rtSec[j]
+			n := len(items)
+			for net, v := range rte.all() {
+				if net&superMask == super {
+					items = append(items, rtItem{slot: i*SectionSize + j, net: net, v: v, hole: rte.hole(net)})
+				}
+			}
+			//delete after the iteration, deleting may switch the rtHash to rtArray
+			for _, item := range items[n:] {
+				rte.del(item.net)
+				delete(rte.holes, item.net)
+				rt.ttl.forget(slotKey{item.slot, item.net})
+			}
+			if len(items) > n && rte.count() == 0 {
+				rt.clearSlotBit(i*SectionSize + j)
+				rt.freeHash(rte)
+			}
+		}
+		sec.Unlock()
+
+		for _, item := range items {
+			rt.notify(RouteDel, item.slot, item.net, item.v, item.hole)
+		}
+		removed += len(items)
+	}
+
+	if slot == defaultSlot && rt.delRoute(defaultSlot, 0) {
+		removed++
+	}
+	return removed, nil
+}
+
+// MoreSpecifics return the routes contained in network whose mask is longer than network's,
+// from the longest mask to the shortest
+func (rt *routeTable) MoreSpecifics(network string) []*net.IPNet {
+	slot, super, err := parseNetwork(network)
+	if err != nil {
+		return nil
+	}
+
+	var nets []*net.IPNet
+	superMask := NetWork(MaskForSlot(slot))
+	for i := 0; i < IpSection && i*SectionSize < slot; i++ {
+		sec := &rt.rts[i]
+		sec.RLock()
+		for j := 0; j < SectionSize && i*SectionSize+j < slot; j++ {
+			for net := range sec.rtSec[j].all() {
+				if net&superMask == super && !sec.rtSec[j].hole(net) {
+					nets = append(nets, slotIPNet(i*SectionSize+j, net))
+				}
+			}
+		}
+		sec.RUnlock()
+	}
+	return nets
+}
@@ -0,0 +1,32 @@
+package routev2
+
+import "sync/atomic"
+
+// Swappable hold a table that is rebuilt as a whole and published atomically,
+// for the periodic full-table refresh: in-flight lookups see either the old table or the new one,
+// never a partial state. the OnChange hooks and lookup cache of the old table are not carried over
+type Swappable struct {
+	rt atomic.Pointer[routeTable]
+}
+
+func NewSwappable() *Swappable {
+	s := new(Swappable)
+	s.rt.Store(NewRouteTable())
+	return s
+}
+
+// Load return the current table, it may be replaced by Reload at any time
+func (s *Swappable) Load() *routeTable {
+	return s.rt.Load()
+}
+
+func (s *Swappable) Lookup(ip NetWork) interface{} {
+	return s.rt.Load().RouteLookup(ip)
+}
+
+// Reload call build to fill a fresh table, then publish it in place of the current one
+func (s *Swappable) Reload(build func(rt *routeTable)) {
+	rt := NewRouteTable()
+	build(rt)
+	s.rt.Store(rt)
+}
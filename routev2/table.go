@@ -0,0 +1,41 @@
+package routev2
+
+import "net"
+
+// Table is the common api of the route tables, the same as route.Table but with routev2.NetWork
+type Table interface {
+	AddRoute(network string, v interface{}) error
+	DelRoute(network string) error
+	RouteLookup(ip NetWork) interface{}
+	Count() int
+}
+
+var _ Table = (*routeTable)(nil)
+
+// LookupOnly is the read only view of a table returned by ReadOnly, for the code that should only
+// lookup. it's a separate type rather than the table itself, so it can't be asserted back to a table
+type LookupOnly interface {
+	RouteLookup(ip NetWork) interface{}
+	Count() int
+	Walk(fn func(network *net.IPNet, v interface{}) bool)
+}
+
+type readOnly struct {
+	rt *routeTable
+}
+
+func (rt *routeTable) ReadOnly() LookupOnly {
+	return readOnly{rt}
+}
+
+func (ro readOnly) RouteLookup(ip NetWork) interface{} {
+	return ro.rt.RouteLookup(ip)
+}
+
+func (ro readOnly) Count() int {
+	return ro.rt.Count()
+}
+
+func (ro readOnly) Walk(fn func(network *net.IPNet, v interface{}) bool) {
+	ro.rt.Walk(fn)
+}
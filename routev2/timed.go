@@ -0,0 +1,35 @@
+package routev2
+
+import (
+	"fmt"
+	"time"
+)
+
+// RouteTimed is stored as the value by AddRouteTimed, so RouteLookup return RouteTimed for these routes.
+// only the routes added by AddRouteTimed pay for the timestamp
+type RouteTimed struct {
+	Value interface{}
+	Added time.Time
+}
+
+// AddRouteTimed add the route with the time it's installed, the age is returned by RouteAge
+func (rt *routeTable) AddRouteTimed(network string, v interface{}) error {
+	return rt.AddRoute(network, RouteTimed{Value: v, Added: time.Now()})
+}
+
+// RouteAge return how long ago the route of network was added by AddRouteTimed,
+// it return ErrRouteNotFound if there is no such route
+func (rt *routeTable) RouteAge(network string) (time.Duration, error) {
+	v, ok, err := rt.GetRoute(network)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrRouteNotFound
+	}
+	timed, ok := v.(RouteTimed)
+	if !ok {
+		return 0, fmt.Errorf("route %s is not added by AddRouteTimed", network)
+	}
+	return time.Since(timed.Added), nil
+}
@@ -0,0 +1,135 @@
+package routev2
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// expiry record the deadlines of the routes added by AddRouteTTL, and the sweeper that remove them.
+// a deadline belong to the route stored by AddRouteTTL, any other add or delete of the network drop it,
+// so the sweeper never remove a route that is added again without TTL
+type expiry struct {
+	mu       sync.Mutex
+	n        atomic.Int64 //len(deadline), the tables without TTL routes don't lock mu on every add
+	deadline map[slotKey]time.Time
+	stop     chan struct{} //nil if the sweeper is not running
+	done     chan struct{}
+}
+
+// AddRouteTTL add the route that is removed by the sweeper after ttl, unless refreshed by AddRouteTTL
+// again. AddRoute, DelRoute, Clear and the other changes of the network cancel the expiry.
+// routes are only removed when the sweeper is running, see StartExpiry
+func (rt *routeTable) AddRouteTTL(network string, v interface{}, ttl time.Duration) error {
+	slot, net, err := parseNetwork(network)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = rt.modifyLimited(slot, net, time.Now().Add(ttl), func(old interface{}, existed bool) (interface{}, routeOp) {
+		return v, opStore
+	})
+	return err
+}
+
+// set the deadline of key, the zero at drop it. it must be called with the section of key locked,
+// so the deadline is changed together with the route
+func (e *expiry) set(key slotKey, at time.Time) {
+	if at.IsZero() && e.n.Load() == 0 {
+		return
+	}
+	e.mu.Lock()
+	if at.IsZero() {
+		delete(e.deadline, key)
+	} else {
+		if e.deadline == nil {
+			e.deadline = make(map[slotKey]time.Time)
+		}
+		e.deadline[key] = at
+	}
+	e.n.Store(int64(len(e.deadline)))
+	e.mu.Unlock()
+}
+
+// forget drop the deadline of key, it must be called with the section of key locked
+func (e *expiry) forget(key slotKey) {
+	e.set(key, time.Time{})
+}
+
+// forgetSlot drop the deadlines of slot, or all deadlines if slot < 0.
+// it must be called with the section of slot locked
+func (e *expiry) forgetSlot(slot int) {
+	if e.n.Load() == 0 {
+		return
+	}
+	e.mu.Lock()
+	for k := range e.deadline {
+		if slot < 0 || k.slot == slot {
+			delete(e.deadline, k)
+		}
+	}
+	e.n.Store(int64(len(e.deadline)))
+	e.mu.Unlock()
+}
+
+// StartExpiry start the sweeper that remove the expired routes every interval,
+// it does nothing if the sweeper is already running
+func (rt *routeTable) StartExpiry(interval time.Duration) {
+	rt.ttl.mu.Lock()
+	defer rt.ttl.mu.Unlock()
+	if rt.ttl.stop != nil {
+		return
+	}
+	rt.ttl.stop, rt.ttl.done = make(chan struct{}), make(chan struct{})
+	go rt.sweepLoop(interval, rt.ttl.stop, rt.ttl.done)
+}
+
+// StopExpiry stop the sweeper and wait for it to exit
+func (rt *routeTable) StopExpiry() {
+	rt.ttl.mu.Lock()
+	stop, done := rt.ttl.stop, rt.ttl.done
+	rt.ttl.stop, rt.ttl.done = nil, nil
+	rt.ttl.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (rt *routeTable) sweepLoop(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			rt.sweep(now)
+		}
+	}
+}
+
+func (rt *routeTable) sweep(now time.Time) {
+	var expired []slotKey
+	rt.ttl.mu.Lock()
+	for k, at := range rt.ttl.deadline {
+		if !now.Before(at) {
+			expired = append(expired, k)
+		}
+	}
+	rt.ttl.mu.Unlock()
+
+	for _, k := range expired {
+		rt.modify(k.slot, k.net, func(old interface{}, existed bool) (interface{}, routeOp) {
+			//check again with the slot locked, the route may be refreshed or replaced meanwhile,
+			//modify drop the deadline with the route
+			rt.ttl.mu.Lock()
+			defer rt.ttl.mu.Unlock()
+			if at, ok := rt.ttl.deadline[k]; !ok || now.Before(at) {
+				return old, opNone
+			}
+			return old, opDelete
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package routev2
+
+import "fmt"
+
+// Validate check the invariants of the table and return the first violation:
+// the slotMask bit of each slot is set if and only if it has routes,
+// the secMask bit of each section is set if and only if its slotMask is not 0,
+// the mask of each slot is right, every key is the network address of its slot,
+// the rtArray is not used with the rtHash, and every exception is a route of the slot.
+// it's for the tests and fuzzing, the table should not be modified meanwhile
+func (rt *routeTable) Validate() error {
+	secMask := rt.secMask.Load()
+	for i := 0; i < IpSection; i++ {
+		sec := &rt.rts[i]
+		sec.RLock()
+		err := sec.validate(i, secMask&(1<<uint32(i)) != 0)
+		sec.RUnlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sec *rtSection) validate(ipID int, bit bool) error {
+	slotMask := sec.slotMask.Load()
+	if bit != (slotMask != 0) {
+		return fmt.Errorf("section %d: secMask bit is %v with slotMask %#x", ipID, bit, slotMask)
+	}
+	for j := 0; j < SectionSize; j++ {
+		if err := sec.rtSec[j].validate(ipID*SectionSize+j, slotMask&(1<<uint32(j)) != 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rte *rtEntry) validate(slot int, bit bool) error {
+	if mask := MaskForSlot(slot); rte.mask != mask {
+		return fmt.Errorf("slot %d: mask %#x, should be %#x", slot, rte.mask, mask)
+	}
+	if bit != (rte.count() > 0) {
+		return fmt.Errorf("slot %d: slotMask bit is %v with %d routes", slot, bit, rte.count())
+	}
+	if rte.rtNum < 0 || rte.rtNum > arrayMax || (rte.rtHash != nil && rte.rtNum > 0) {
+		return fmt.Errorf("slot %d: %d routes in rtArray with rtHash %v", slot, rte.rtNum, rte.rtHash != nil)
+	}
+	for net := range rte.all() {
+		if rte.key(net) != net {
+			return fmt.Errorf("slot %d: key %v is not a /%d network", slot, NetWorkToIP(net), maskMaxLen-slot)
+		}
+	}
+	for net := range rte.holes {
+		if _, ok := rte.get(net); !ok {
+			return fmt.Errorf("slot %d: exception %v is not a route", slot, slotIPNet(slot, net))
+		}
+	}
+	return nil
+}
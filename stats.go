@@ -0,0 +1,56 @@
+package route
+
+// RouteStats is the summary of the table returned by Stats
+type RouteStats struct {
+	Total         int
+	PerMask       [maskMaxLen + 1]int //indexed by mask length
+	OccupiedSlots int
+	SlotMask      uint64 //bit slot is set if the slot has routes, bit defaultSlot is the default route
+
+	CacheHits   uint64 //the lookup cache stats, 0 if EnableLookupCache is not called
+	CacheMisses uint64
+}
+
+// Stats gather the stats of the table, each slot is read under its read lock
+func (rt *RouteTable[T]) Stats() RouteStats {
+	var st RouteStats
+	for i := range rt.rts {
+		rt.rts[i].RLock()
+		n := rt.rts[i].count()
+		rt.rts[i].RUnlock()
+		st.add(i, n)
+	}
+	if rt.hasDefault.Load() {
+		st.add(defaultSlot, 1)
+	}
+	if c := rt.cache.Load(); c != nil {
+		st.CacheHits, st.CacheMisses = c.hits.Load(), c.misses.Load()
+	}
+	return st
+}
+
+func (st *RouteStats) add(slot, n int) {
+	if n == 0 {
+		return
+	}
+	st.Total += n
+	st.PerMask[maskMaxLen-slot] = n
+	st.OccupiedSlots++
+	st.SlotMask |= 1 << uint64(slot)
+}
+
+// ActiveMaskLengths return the mask lengths that have routes, from the longest to the shortest
+// like the lookup order. it only read the slotMask bits, without locking any slot
+func (rt *RouteTable[T]) ActiveMaskLengths() []int {
+	var lens []int
+	for i, mask := 0, rt.slotMask.Load(); mask != 0; i++ {
+		if mask&1 != 0 {
+			lens = append(lens, maskMaxLen-i)
+		}
+		mask >>= 1
+	}
+	if rt.hasDefault.Load() {
+		lens = append(lens, 0)
+	}
+	return lens
+}
@@ -0,0 +1,69 @@
+package route
+
+import "net"
+
+// DelSubtree delete all routes contained in network, including network itself,
+// e.g. DelSubtree("10.1.0.0/16") delete 10.1.0.0/16, 10.1.2.0/24, 10.1.2.3/32 and so on.
+// it return the number of routes removed
+func (rt *RouteTable[T]) DelSubtree(network string) (removed int, err error) {
+	slot, super, err := parseNetwork(network)
+	if err != nil {
+		return 0, err
+	}
+
+	superMask := NetWork(MaskForSlot(slot))
+	for i := 0; i <= slot && i < maskMaxLen; i++ {
+		var items []rtItem[T]
+		rte := &rt.rts[i]
+		rte.Lock()
+		for net, v := range rte.all() {
+			if net&superMask == super {
+				items = append(items, rtItem[T]{slot: i, net: net, v: v, hole: rte.hole(net)})
+			}
+		}
+		//delete after the iteration, deleting may switch the rtHash to rtArray
+		for _, item := range items {
+			rte.del(item.net)
+			delete(rte.holes, item.net)
+			rt.ttl.forget(slotKey{item.slot, item.net})
+		}
+		if len(items) > 0 && rte.count() == 0 {
+			rt.clearSlotBit(i)
+			rt.freeHash(rte)
+		}
+		rte.Unlock()
+
+		for _, item := range items {
+			rt.notify(RouteDel, item.slot, item.net, item.v, item.hole)
+		}
+		removed += len(items)
+	}
+
+	if slot == defaultSlot && rt.delRoute(defaultSlot, 0) {
+		removed++
+	}
+	return removed, nil
+}
+
+// MoreSpecifics return the routes contained in network whose mask is longer than network's,
+// from the longest mask to the shortest
+func (rt *RouteTable[T]) MoreSpecifics(network string) []*net.IPNet {
+	slot, super, err := parseNetwork(network)
+	if err != nil {
+		return nil
+	}
+
+	var nets []*net.IPNet
+	superMask := NetWork(MaskForSlot(slot))
+	for i := 0; i < slot && i < maskMaxLen; i++ {
+		rte := &rt.rts[i]
+		rte.RLock()
+		for net := range rte.all() {
+			if net&superMask == super && !rte.hole(net) {
+				nets = append(nets, slotIPNet(i, net))
+			}
+		}
+		rte.RUnlock()
+	}
+	return nets
+}
@@ -0,0 +1,38 @@
+package route
+
+import "sync/atomic"
+
+// SwappableOf hold a table that is rebuilt as a whole and published atomically,
+// for the periodic full-table refresh: in-flight lookups see either the old table or the new one,
+// never a partial state. the OnChange hooks and lookup cache of the old table are not carried over
+type SwappableOf[T any] struct {
+	rt atomic.Pointer[RouteTable[T]]
+}
+
+type Swappable = SwappableOf[interface{}]
+
+func NewSwappable() *Swappable {
+	return NewSwappableOf[interface{}]()
+}
+
+func NewSwappableOf[T any]() *SwappableOf[T] {
+	s := new(SwappableOf[T])
+	s.rt.Store(NewRouteTableOf[T]())
+	return s
+}
+
+// Load return the current table, it may be replaced by Reload at any time
+func (s *SwappableOf[T]) Load() *RouteTable[T] {
+	return s.rt.Load()
+}
+
+func (s *SwappableOf[T]) Lookup(ip NetWork) (T, bool) {
+	return s.rt.Load().RouteLookup(ip)
+}
+
+// Reload call build to fill a fresh table, then publish it in place of the current one
+func (s *SwappableOf[T]) Reload(build func(rt *RouteTable[T])) {
+	rt := NewRouteTableOf[T]()
+	build(rt)
+	s.rt.Store(rt)
+}
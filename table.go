@@ -0,0 +1,82 @@
+package route
+
+import "net"
+
+// Table is the common api of the interface{} route tables, code written against Table
+// can switch between NewRouteTable, routev2 and so on without changing the call sites
+type Table interface {
+	AddRoute(network string, v interface{}) error
+	DelRoute(network string) error
+	RouteLookup(ip NetWork) interface{}
+	Count() int
+}
+
+// TableOf is the common api of the generic route tables
+type TableOf[T any] interface {
+	AddRoute(network string, v T) error
+	DelRoute(network string) error
+	RouteLookup(ip NetWork) (T, bool)
+	Count() int
+}
+
+var (
+	_ Table                = (*routeTable)(nil)
+	_ TableOf[interface{}] = (*RouteTable[interface{}])(nil)
+	_ TableOf[interface{}] = (*RCURouteTable[interface{}])(nil)
+	_ TableOf[interface{}] = (*TrieRouteTable[interface{}])(nil)
+)
+
+// LookupOnly is the read only view of a table returned by ReadOnly, for the code that should only
+// lookup. it's a separate type rather than the table itself, so it can't be asserted back to a table
+type LookupOnly interface {
+	RouteLookup(ip NetWork) interface{}
+	Count() int
+	Walk(fn func(network *net.IPNet, v interface{}) bool)
+}
+
+// LookupOnlyOf is the read only view of the generic table
+type LookupOnlyOf[T any] interface {
+	RouteLookup(ip NetWork) (T, bool)
+	Count() int
+	Walk(fn func(network *net.IPNet, v T) bool)
+}
+
+type readOnly struct {
+	rt *routeTable
+}
+
+type readOnlyOf[T any] struct {
+	rt *RouteTable[T]
+}
+
+func (rt *routeTable) ReadOnly() LookupOnly {
+	return readOnly{rt}
+}
+
+func (rt *RouteTable[T]) ReadOnly() LookupOnlyOf[T] {
+	return readOnlyOf[T]{rt}
+}
+
+func (ro readOnly) RouteLookup(ip NetWork) interface{} {
+	return ro.rt.RouteLookup(ip)
+}
+
+func (ro readOnly) Count() int {
+	return ro.rt.Count()
+}
+
+func (ro readOnly) Walk(fn func(network *net.IPNet, v interface{}) bool) {
+	ro.rt.Walk(fn)
+}
+
+func (ro readOnlyOf[T]) RouteLookup(ip NetWork) (T, bool) {
+	return ro.rt.RouteLookup(ip)
+}
+
+func (ro readOnlyOf[T]) Count() int {
+	return ro.rt.Count()
+}
+
+func (ro readOnlyOf[T]) Walk(fn func(network *net.IPNet, v T) bool) {
+	ro.rt.Walk(fn)
+}
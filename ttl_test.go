@@ -0,0 +1,35 @@
+package route
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRouteTTL(t *testing.T) {
+	rt := NewRouteTableOf[int]()
+	later := time.Now().Add(2 * time.Hour)
+	rt.AddRouteTTL("10.1.0.0/16", 1, time.Hour)
+	rt.AddRouteTTL("10.2.0.0/16", 2, time.Hour)
+	rt.AddRouteTTL("10.3.0.0/16", 3, time.Hour)
+	rt.AddRouteTTL("10.4.0.0/16", 4, 3*time.Hour)
+	rt.AddRoute("10.2.0.0/16", 20) //AddRoute cancel the expiry
+	rt.DelRoute("10.3.0.0/16")
+	rt.AddRoute("10.3.0.0/16", 30)
+
+	rt.sweep(later)
+	for network, want := range map[string]int{"10.2.0.0/16": 20, "10.3.0.0/16": 30, "10.4.0.0/16": 4} {
+		if v, ok, _ := rt.GetRoute(network); !ok || v != want {
+			t.Errorf("%s = %v, %v after the sweep, want %v", network, v, ok, want)
+		}
+	}
+	if _, ok, _ := rt.GetRoute("10.1.0.0/16"); ok {
+		t.Error("10.1.0.0/16 is not expired")
+	}
+
+	rt.Clear()
+	rt.AddRoute("10.4.0.0/16", 40) //Clear drop the deadline of the old 10.4.0.0/16
+	rt.sweep(later.Add(2 * time.Hour))
+	if v, ok, _ := rt.GetRoute("10.4.0.0/16"); !ok || v != 40 {
+		t.Errorf("10.4.0.0/16 = %v, %v after Clear and the sweep, want 40", v, ok)
+	}
+}
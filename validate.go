@@ -0,0 +1,57 @@
+package route
+
+import "fmt"
+
+// Validate check the invariants of the table and return the first violation:
+// the slotMask bit of each slot is set if and only if it has routes,
+// every key is the network address of its slot,
+// the rtArray is sorted and not used with the rtHash, and every exception is a route of the slot.
+// it's for the tests and fuzzing, the table should not be modified meanwhile
+func (rt *RouteTable[T]) Validate() error {
+	slotMask := rt.slotMask.Load()
+	for i := range rt.rts {
+		rte := &rt.rts[i]
+		rte.RLock()
+		err := rte.validate(i, slotMask&(1<<uint32(i)) != 0)
+		rte.RUnlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rte *rtEntry[T]) validate(slot int, bit bool) error {
+	if bit != (rte.count() > 0) {
+		return fmt.Errorf("slot %d: slotMask bit is %v with %d routes", slot, bit, rte.count())
+	}
+	if err := rte.validateArray(slot); err != nil {
+		return err
+	}
+	for net := range rte.all() {
+		if netKey(slot, net) != net {
+			return fmt.Errorf("slot %d: key %v is not a /%d network", slot, NetWorkToIP(net), maskMaxLen-slot)
+		}
+	}
+	for net := range rte.holes {
+		if _, ok := rte.get(net); !ok {
+			return fmt.Errorf("slot %d: exception %v is not a route", slot, slotIPNet(slot, net))
+		}
+	}
+	return nil
+}
+
+func (rte *rtEntry[T]) validateArray(slot int) error {
+	if rte.rtHash != nil && len(rte.rtKeys) > 0 {
+		return fmt.Errorf("slot %d: %d routes in rtArray with rtHash", slot, len(rte.rtKeys))
+	}
+	if len(rte.rtKeys) != len(rte.rtVals) || len(rte.rtKeys) > arrayMax {
+		return fmt.Errorf("slot %d: rtArray has %d keys and %d values", slot, len(rte.rtKeys), len(rte.rtVals))
+	}
+	for i := 1; i < len(rte.rtKeys); i++ {
+		if rte.rtKeys[i-1] >= rte.rtKeys[i] {
+			return fmt.Errorf("slot %d: rtArray is not sorted at %d", slot, i)
+		}
+	}
+	return nil
+}